@@ -28,25 +28,36 @@ type AciClient struct {
 	token      string
 }
 
-func (s *AciClient) Login() error {
-	err := s.login()
+func (s *AciClient) Login(ctx context.Context) error {
+	err := s.login(ctx)
 	return err
 }
 
-func (s *AciClient) Logout() error {
+func (s *AciClient) Logout(ctx context.Context) error {
 	return s.logout()
 }
 
-func (s *AciClient) DoRequest(method string, uri string, payload *string) (string, error) {
+func (s *AciClient) DoRequest(ctx context.Context, method string, uri string, payload *string) (string, error) {
 
 	switch method {
 	case "GET":
-		return s.aciGet(uri)
+		return s.aciGet(ctx, uri)
 	default:
 		return "", fmt.Errorf("Unimplemented method %s used in ACI Client", method)
 	}
 }
 
+// DoRequestWithHeaders - implement interface jsonscraper.ScraperHeaderClient
+func (s *AciClient) DoRequestWithHeaders(ctx context.Context, method string, uri string, payload *string) (string, http.Header, error) {
+
+	switch method {
+	case "GET":
+		return s.aciGetWithHeaders(ctx, uri)
+	default:
+		return "", nil, fmt.Errorf("Unimplemented method %s used in ACI Client", method)
+	}
+}
+
 type dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
 
 func getAciClient(config component.Config, logger *zap.Logger) (*AciClient, error) {
@@ -109,7 +120,12 @@ func (s *AciClient) getHost() string {
 	return fmt.Sprintf("%s://%s:%d", cfg.Protocol, cfg.Host, cfg.Port)
 }
 
-func (s *AciClient) login() error {
+// Close - releases idle connections kept by the HTTP client
+func (s *AciClient) Close() {
+	s.httpClient.CloseIdleConnections()
+}
+
+func (s *AciClient) login(ctx context.Context) error {
 	cfg := s.config
 	credsJson := fmt.Sprintf(`
 	{
@@ -123,11 +139,19 @@ func (s *AciClient) login() error {
 	`, cfg.User, cfg.Password)
 	creds := bytes.NewBufferString(credsJson)
 
-	response, err := s.httpClient.Post(s.getHost()+"/api/aaaLogin.json", "application/json", creds)
+	request, err := http.NewRequestWithContext(ctx, "POST", s.getHost()+"/api/aaaLogin.json", creds)
+	if err != nil {
+		s.logger.Error("Error creating login request to APIC", zap.Error(err))
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := s.httpClient.Do(request)
 	if err != nil {
 		s.logger.Error("Error logging to APIC", zap.Error(err))
 		return err
 	}
+	defer response.Body.Close()
 
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
@@ -153,13 +177,25 @@ func (s *AciClient) logout() error {
 	return nil
 }
 
-func (s *AciClient) aciGet(uri string) (string, error) {
+func (s *AciClient) aciGet(ctx context.Context, uri string) (string, error) {
+	body, _, err := s.aciGetWithHeaders(ctx, uri)
+	return body, err
+}
+
+func (s *AciClient) aciGetWithHeaders(ctx context.Context, uri string) (string, http.Header, error) {
 
 	s.logger.Debug("APIC GET request", zap.Any("URI", s.getHost()+uri))
 
-	response, err := s.httpClient.Get(s.getHost() + uri)
+	request, err := http.NewRequestWithContext(ctx, "GET", s.getHost()+uri, nil)
+	if err != nil {
+		s.logger.Error("Error creating GET request to APIC", zap.Error(err))
+		return "", nil, err
+	}
+
+	response, err := s.httpClient.Do(request)
 	if err != nil {
 		s.logger.Error("Error sending GET to APIC", zap.Error(err))
+		return "", nil, err
 	}
 	defer response.Body.Close()
 
@@ -176,5 +212,5 @@ func (s *AciClient) aciGet(uri string) (string, error) {
 
 	// s.logger.Debug("APIC GET response", zap.Any("GET response body", jsonResponse))
 
-	return string(body), nil
+	return string(body), response.Header, nil
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	contextdb "github.com/chrlic/otelcol-cust/collector/shared/contextdb"
 	"github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
 	"go.opentelemetry.io/collector/component"
 )
@@ -133,11 +134,20 @@ type ContextSubscription struct {
 
 // Config - represents the receivers' configuration in config.yaml file of the collector
 type Config struct {
-	Interval int       `mapstructure:"interval"`
-	Aci      AciConfig `mapstructure:"aci"`
+	Interval       int       `mapstructure:"interval"`
+	MaxConcurrency int       `mapstructure:"maxConcurrency"`
+	OverlapPolicy  string    `mapstructure:"overlapPolicy"`
+	ScrapeTimeout  int       `mapstructure:"scrapeTimeout"`
+	Jitter         int       `mapstructure:"jitter"`
+	FlushSize      int       `mapstructure:"flushSize"`
+	RecordDir      string    `mapstructure:"recordDir"`      // requests and responses are written there as test fixtures
+	ErrorLogs      bool      `mapstructure:"errorLogs"`      // log receiver emits collection errors as log records
+	ReloadInterval int       `mapstructure:"reloadInterval"` // seconds between checks of queries and tableSchemas files for changes, 0 (default) disables reload
+	Aci            AciConfig `mapstructure:"aci"`
 	// Resource         *AciResource       `mapstructure:"resource"`
 	// Scope            *AciScope          `mapstructure:"scope"`
 	QueryFiles       []string           `mapstructure:"queries"`
+	InlineQueries    map[string]any     `mapstructure:"inlineQueries"` // queries, templates and fragments like in query files
 	DbSchemas        []string           `mapstructure:"tableSchemas"`
 	ContextProviders []*ContextProvider `mapstructure:"contextProviders"`
 	ScraperConfig    jsonscraper.Config
@@ -161,11 +171,32 @@ func (cfg *Config) Validate() error {
 	if cfg.Aci.Password == "" {
 		return fmt.Errorf("aci.password is mandatory and missing")
 	}
-	if len(cfg.QueryFiles) == 0 {
-		return fmt.Errorf("at least one query file required")
+	if len(cfg.QueryFiles) == 0 && cfg.InlineQueries == nil {
+		return fmt.Errorf("at least one query file or inlineQueries required")
+	}
+	if !jsonscraper.ScrapePolicy(cfg.OverlapPolicy).IsValid() {
+		return fmt.Errorf("overlapPolicy %s is invalid, use one of skip, queue, cancelPrevious", cfg.OverlapPolicy)
+	}
+	if cfg.ScrapeTimeout < 0 {
+		return fmt.Errorf("scrapeTimeout must not be negative")
+	}
+	if cfg.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	if cfg.FlushSize < 0 {
+		return fmt.Errorf("flushSize must not be negative")
+	}
+	if cfg.ReloadInterval < 0 {
+		return fmt.Errorf("reloadInterval must not be negative")
 	}
 
 	cfg.ScraperConfig = jsonscraper.NewScraperConfig()
+	cfg.ScraperConfig.MaxConcurrency = cfg.MaxConcurrency
+	cfg.ScraperConfig.OverlapPolicy = jsonscraper.ScrapePolicy(cfg.OverlapPolicy)
+	cfg.ScraperConfig.ScrapeTimeout = cfg.ScrapeTimeout
+	cfg.ScraperConfig.Jitter = cfg.Jitter
+	cfg.ScraperConfig.FlushSize = cfg.FlushSize
+	cfg.ScraperConfig.ErrorLogs = cfg.ErrorLogs
 
 	// resourcesInQueries := true
 	// scopesInQueries := true
@@ -176,10 +207,19 @@ func (cfg *Config) Validate() error {
 				return fmt.Errorf("intersight.queries: cannot read config file %s - %v", confFile, err)
 			}
 
-			cfg.ScraperConfig.AddQueryRules(queryConfig)
-
+			if err = cfg.ScraperConfig.AddQueryRulesFrom(confFile, queryConfig); err != nil {
+				return fmt.Errorf("aci.queries: %v", err)
+			}
+		}
+	}
+	if cfg.InlineQueries != nil {
+		if err := cfg.ScraperConfig.AddInlineQueries("inlineQueries", cfg.InlineQueries); err != nil {
+			return fmt.Errorf("aci.queries: %v", err)
 		}
 	}
+	if err := cfg.validateTables(); err != nil {
+		return err
+	}
 
 	// if cfg.Resource == nil && !resourcesInQueries {
 	// 	return fmt.Errorf("resource must be specified either globally or in each query")
@@ -226,3 +266,25 @@ func createDefaultConfig() component.Config {
 
 	return cfg
 }
+
+// validateTables - checks that emitDbRecord rules write to tables of tableSchemas
+func (cfg *Config) validateTables() error {
+	tables := []string{}
+	for _, schemaFile := range cfg.DbSchemas {
+		schemaConfig, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return fmt.Errorf("aci.tableSchemas: cannot read db schema yaml %s - %v", schemaFile, err)
+		}
+		schema, err := contextdb.ParseDbJsonSchema(schemaConfig)
+		if err != nil {
+			return fmt.Errorf("aci.tableSchemas: cannot parse db schema file %s - %v", schemaFile, err)
+		}
+		for _, table := range schema {
+			tables = append(tables, table.Name)
+		}
+	}
+	if err := cfg.ScraperConfig.ValidateTables(tables); err != nil {
+		return fmt.Errorf("aci.queries: %v", err)
+	}
+	return nil
+}
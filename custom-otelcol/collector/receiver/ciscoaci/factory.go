@@ -34,6 +34,7 @@ func createAciReceiver(
 		config:           cfg,
 		logger:           settings.Logger,
 		receiverID:       settings.ID.String(),
+		telemetry:        settings.TelemetrySettings,
 		isMetricReceiver: true,
 		isLogReceiver:    false,
 	}, nil
@@ -46,6 +47,7 @@ func createAciLogReceiver(_ context.Context, settings receiver.CreateSettings, c
 		config:           cfg,
 		logger:           settings.Logger,
 		receiverID:       settings.ID.String(),
+		telemetry:        settings.TelemetrySettings,
 		isMetricReceiver: false,
 		isLogReceiver:    true,
 	}, nil
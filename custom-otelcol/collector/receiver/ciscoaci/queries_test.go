@@ -0,0 +1,27 @@
+package ciscoaci
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+var queryFiles = []jsonscraper.GoldenQuery{
+	{File: "aci-node-pwr.yaml", Signal: "metrics"},
+	{File: "aci-sys-hf.yaml", Signal: "metrics"},
+	{File: "aci-sys-logs.yaml", Signal: "logs"},
+}
+
+func TestQueryFilesGolden(t *testing.T) {
+	for _, q := range queryFiles {
+		q := q
+		t.Run(q.File, func(t *testing.T) {
+			if err := jsonscraper.CompareGolden(q, "../../../conf/aci", "testdata/fixtures", "testdata/golden", *update); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
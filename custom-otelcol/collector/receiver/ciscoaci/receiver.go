@@ -3,7 +3,7 @@ package ciscoaci
 import (
 	"context"
 	"fmt"
-	"os"
+	"time"
 
 	contextdb "github.com/chrlic/otelcol-cust/collector/shared/contextdb"
 	"github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
@@ -20,9 +20,13 @@ type aciReceiver struct {
 	config           component.Config
 	ctx              context.Context
 	receiverID       string
+	telemetry        component.TelemetrySettings
 	isMetricReceiver bool
 	isLogReceiver    bool
 	contextDb        contextdb.ContextDb
+	scraper          *jsonscraper.Scraper
+	watcher          *jsonscraper.FileWatcher
+	aciClient        *AciClient
 }
 
 func (r *aciReceiver) Start(ctx context.Context, host component.Host) error {
@@ -44,9 +48,31 @@ func (r *aciReceiver) Start(ctx context.Context, host component.Host) error {
 		r.logger.Sugar().Errorf("Cannot initialize Intersight SDK client - %v", err)
 		return err
 	}
+	var client jsonscraper.ScraperClient = aciClient
+	if cfg.RecordDir != "" {
+		client, err = jsonscraper.NewRecordingClient(r.logger, aciClient, cfg.RecordDir, cfg.Aci.Password)
+		if err != nil {
+			r.logger.Sugar().Errorf("Cannot record fixtures - %v", err)
+			return err
+		}
+	}
 	emitter := jsonscraper.NewEmitter(ctx, r.logger, r.metricConsumer, r.logConsumer)
-	scraper := jsonscraper.NewScraper(r.receiverID, r.logger, aciClient, emitter, cfg.ScraperConfig, cfg.Interval, &r.contextDb)
+	scraper := jsonscraper.NewScraper(r.receiverID, r.logger, client, emitter, cfg.ScraperConfig, cfg.Interval, &r.contextDb)
+	// metrics and logs receivers of the same APIC share responses of queries with cacheTTL
+	scraper.SetResponseCache(jsonscraper.SharedResponseCache(fmt.Sprintf("aci %s://%s@%s:%d", cfg.Aci.Protocol, cfg.Aci.User, cfg.Aci.Host, cfg.Aci.Port)))
+	if err = scraper.SetMeterProvider(r.telemetry.MeterProvider); err != nil {
+		r.logger.Sugar().Errorf("Cannot create scraper telemetry - %v", err)
+		return err
+	}
 	scraper.Run()
+	r.scraper = &scraper
+
+	if cfg.ReloadInterval > 0 {
+		watched := append(append([]string{}, cfg.QueryFiles...), cfg.DbSchemas...)
+		r.watcher = jsonscraper.NewFileWatcher(r.logger, append(watched, cfg.ScraperConfig.Includes()...)...)
+		go r.watcher.Watch(r.ctx, time.Duration(cfg.ReloadInterval)*time.Second, r.reloadConfig)
+	}
+	r.aciClient = aciClient
 
 	return nil
 
@@ -71,45 +97,53 @@ func (r *aciReceiver) Shutdown(ctx context.Context) error {
 	if r.cancel != nil {
 		r.cancel()
 	}
-	return nil
+	var err error
+	if r.scraper != nil {
+		err = r.scraper.Stop(ctx)
+	}
+	if r.aciClient != nil {
+		r.aciClient.Close()
+	}
+	return err
 }
 
 func (r *aciReceiver) initContextDb() error {
 	config := r.config.(*Config)
-	ctxDb := contextdb.ContextDb{}
 
-	dbJsonSchemas := []*contextdb.ContextTableSchema{}
+	dbSchema, err := contextdb.LoadDbSchema(config.DbSchemas)
+	if err != nil {
+		return err
+	}
+	if dbSchema == nil {
+		r.logger.Sugar().Info("Context DB node defined")
+		return nil
+	}
 
-	for _, schema := range config.DbSchemas {
-		schemaConfig, err := os.ReadFile(schema)
-		if err != nil {
-			return fmt.Errorf("cannot read db schema yaml %s - %v", schema, err)
-		}
+	err = r.contextDb.Init(dbSchema, r.logger)
+	if err != nil {
+		return fmt.Errorf("cannot init DB %v - %v", dbSchema, err)
+	}
 
-		dbJsonSchema, err := contextdb.ParseDbJsonSchema(schemaConfig)
-		if err != nil {
-			return fmt.Errorf("cannot parse db schema file %s - %v", schema, err)
-		}
+	return nil
+}
 
-		dbJsonSchemas = contextdb.AppendDbJsonSchema(dbJsonSchemas, dbJsonSchema)
+// reloadConfig - validates the changed queries and tableSchemas and swaps them into the running scraper,
+// the context DB keeps records of the tables which remain in the schemas and is migrated with the queries
+func (r *aciReceiver) reloadConfig(changed []string) error {
+	cfg := *r.config.(*Config)
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
+	// files newly included by the queries
+	r.watcher.Add(cfg.ScraperConfig.Includes()...)
 
-	if len(dbJsonSchemas) > 0 {
-		dbSchema, err := contextdb.GetDbSchema(dbJsonSchemas)
-		if err != nil {
-			return fmt.Errorf("cannot convert schema to memdb schema %v - %v", dbSchema, err)
-		}
-		err = ctxDb.Init(dbSchema, r.logger)
-		if err != nil {
-			return fmt.Errorf("cannot init DB %v - %v", dbSchema, err)
-		}
-
-		r.contextDb = ctxDb
-	} else {
-		r.logger.Sugar().Info("Context DB node defined")
+	// the schema is prepared now and swapped together with the queries while no scrape runs
+	migrate, err := r.contextDb.ReloadMigration(cfg.DbSchemas, changed, r.logger)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return r.scraper.Reload(cfg.ScraperConfig, migrate)
 }
 
 func (r *aciReceiver) subscribeToExtensions(host component.Host, config *Config) {
@@ -134,7 +168,7 @@ func (r *aciReceiver) subscribeToExtensions(host component.Host, config *Config)
 				}
 				r.logger.Sugar().Infof("Subscribed to Extension %s", ctxProvider.Name)
 				if r.contextDb.Db != nil {
-					subContext.AttachContextDb(r.contextDb, subscr.Topic)
+					subContext.AttachContextDb(&r.contextDb, subscr.Topic)
 				} else {
 					r.logger.Sugar().Warn("Subscribed for data from extension but no context DB schema defined")
 				}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	contextdb "github.com/chrlic/otelcol-cust/collector/shared/contextdb"
 	"github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
 	"go.opentelemetry.io/collector/component"
 )
@@ -27,10 +28,19 @@ type IntersightConfig struct {
 // Config - represents the receivers' configuration in config.yaml file of the collector
 type Config struct {
 	Interval         int                   `mapstructure:"interval"`
+	MaxConcurrency   int                   `mapstructure:"maxConcurrency"`
+	OverlapPolicy    string                `mapstructure:"overlapPolicy"`
+	ScrapeTimeout    int                   `mapstructure:"scrapeTimeout"`
+	Jitter           int                   `mapstructure:"jitter"`
+	FlushSize        int                   `mapstructure:"flushSize"`
+	RecordDir        string                `mapstructure:"recordDir"`      // requests and responses are written there as test fixtures
+	ErrorLogs        bool                  `mapstructure:"errorLogs"`      // log receiver emits collection errors as log records
+	ReloadInterval   int                   `mapstructure:"reloadInterval"` // seconds between checks of queries and tableSchemas files for changes, 0 (default) disables reload
 	Intersight       IntersightConfig      `mapstructure:"intersight"`
 	Resource         *jsonscraper.Resource `mapstructure:"resource"`
 	Scope            *jsonscraper.Scope    `mapstructure:"scope"`
 	QueryFiles       []string              `mapstructure:"queryFiles"`
+	InlineQueries    map[string]any        `mapstructure:"inlineQueries"` // queries, templates and fragments like in query files
 	DbSchemas        []string              `mapstructure:"tableSchemas"`
 	ContextProviders []*ContextProvider    `mapstructure:"contextProviders"`
 	ScraperConfig    jsonscraper.Config
@@ -39,11 +49,32 @@ type Config struct {
 // Validate - check validity of the configuration
 func (cfg *Config) Validate() error {
 
-	if len(cfg.QueryFiles) == 0 {
-		return fmt.Errorf("at least one query file required")
+	if len(cfg.QueryFiles) == 0 && cfg.InlineQueries == nil {
+		return fmt.Errorf("at least one query file or inlineQueries required")
+	}
+	if !jsonscraper.ScrapePolicy(cfg.OverlapPolicy).IsValid() {
+		return fmt.Errorf("overlapPolicy %s is invalid, use one of skip, queue, cancelPrevious", cfg.OverlapPolicy)
+	}
+	if cfg.ScrapeTimeout < 0 {
+		return fmt.Errorf("scrapeTimeout must not be negative")
+	}
+	if cfg.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	if cfg.FlushSize < 0 {
+		return fmt.Errorf("flushSize must not be negative")
+	}
+	if cfg.ReloadInterval < 0 {
+		return fmt.Errorf("reloadInterval must not be negative")
 	}
 
 	cfg.ScraperConfig = jsonscraper.NewScraperConfig()
+	cfg.ScraperConfig.MaxConcurrency = cfg.MaxConcurrency
+	cfg.ScraperConfig.OverlapPolicy = jsonscraper.ScrapePolicy(cfg.OverlapPolicy)
+	cfg.ScraperConfig.ScrapeTimeout = cfg.ScrapeTimeout
+	cfg.ScraperConfig.Jitter = cfg.Jitter
+	cfg.ScraperConfig.FlushSize = cfg.FlushSize
+	cfg.ScraperConfig.ErrorLogs = cfg.ErrorLogs
 
 	resourcesInQueries := true
 	scopesInQueries := true
@@ -54,10 +85,19 @@ func (cfg *Config) Validate() error {
 				return fmt.Errorf("intersight.queries: cannot read config file %s - %v", confFile, err)
 			}
 
-			cfg.ScraperConfig.AddQueryRules(queryConfig)
-
+			if err = cfg.ScraperConfig.AddQueryRulesFrom(confFile, queryConfig); err != nil {
+				return fmt.Errorf("intersight.queries: %v", err)
+			}
+		}
+	}
+	if cfg.InlineQueries != nil {
+		if err := cfg.ScraperConfig.AddInlineQueries("inlineQueries", cfg.InlineQueries); err != nil {
+			return fmt.Errorf("intersight.queries: %v", err)
 		}
 	}
+	if err := cfg.validateTables(); err != nil {
+		return err
+	}
 
 	if cfg.Resource == nil && !resourcesInQueries {
 		return fmt.Errorf("resource must be specified either globally or in each query")
@@ -75,3 +115,25 @@ func createDefaultConfig() component.Config {
 
 	return cfg
 }
+
+// validateTables - checks that emitDbRecord rules write to tables of tableSchemas
+func (cfg *Config) validateTables() error {
+	tables := []string{}
+	for _, schemaFile := range cfg.DbSchemas {
+		schemaConfig, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return fmt.Errorf("intersight.tableSchemas: cannot read db schema yaml %s - %v", schemaFile, err)
+		}
+		schema, err := contextdb.ParseDbJsonSchema(schemaConfig)
+		if err != nil {
+			return fmt.Errorf("intersight.tableSchemas: cannot parse db schema file %s - %v", schemaFile, err)
+		}
+		for _, table := range schema {
+			tables = append(tables, table.Name)
+		}
+	}
+	if err := cfg.ScraperConfig.ValidateTables(tables); err != nil {
+		return fmt.Errorf("intersight.queries: %v", err)
+	}
+	return nil
+}
@@ -34,6 +34,7 @@ func createIntersightMetricReceiver(
 		config:           cfg,
 		logger:           settings.Logger,
 		receiverID:       settings.ID.String(),
+		telemetry:        settings.TelemetrySettings,
 		isMetricReceiver: true,
 		isLogReceiver:    false,
 	}, nil
@@ -46,6 +47,7 @@ func createIntersightLogReceiver(_ context.Context, settings receiver.CreateSett
 		config:           cfg,
 		logger:           settings.Logger,
 		receiverID:       settings.ID.String(),
+		telemetry:        settings.TelemetrySettings,
 		isMetricReceiver: false,
 		isLogReceiver:    true,
 	}, nil
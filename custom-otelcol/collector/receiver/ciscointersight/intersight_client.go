@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"net/url"
 
 	"github.com/chrlic/otelcol-cust/collector/receiver/ciscointersight/intersightsdk"
@@ -61,33 +62,69 @@ func getIntersightSDKClient(config component.Config, logger *zap.Logger) (*Inter
 	return &client, nil
 }
 
+// authRequestContext - cancellation comes from the scrape context, values needed for
+// request signing from the SDK's authentication context
+type authRequestContext struct {
+	context.Context
+	authCtx context.Context
+}
+
+func (c authRequestContext) Value(key any) any {
+	if value := c.authCtx.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
+
+func (s *IntersightClient) requestContext(ctx context.Context) context.Context {
+	return authRequestContext{
+		Context: ctx,
+		authCtx: *s.AuthCtx,
+	}
+}
+
 // Implement interface jsonscraper.ScrapperClient
 
-func (s *IntersightClient) Login() error {
+func (s *IntersightClient) Login(ctx context.Context) error {
 	return nil
 }
 
-func (s *IntersightClient) Logout() error {
+func (s *IntersightClient) Logout(ctx context.Context) error {
 	return nil
 }
 
-func (s *IntersightClient) DoRequest(method string, uri string, payload *string) (string, error) {
+func (s *IntersightClient) DoRequest(ctx context.Context, method string, uri string, payload *string) (string, error) {
 	switch method {
 	case "GET":
-		return s.intersightGet(uri)
+		return s.intersightGet(ctx, uri)
 	case "POST":
-		return s.intersightPost(uri, payload)
+		return s.intersightPost(ctx, uri, payload)
 	default:
 		return "unimplemented", fmt.Errorf("Method %s not supported", method)
 	}
 }
 
-func (s *IntersightClient) intersightGet(uri string) (string, error) {
+// DoRequestWithHeaders - implement interface jsonscraper.ScraperHeaderClient
+func (s *IntersightClient) DoRequestWithHeaders(ctx context.Context, method string, uri string, payload *string) (string, http.Header, error) {
+	switch method {
+	case "GET":
+		return s.intersightGetWithHeaders(ctx, uri)
+	default:
+		return "", nil, fmt.Errorf("Unimplemented method %s used with response headers in Intersight Client", method)
+	}
+}
+
+func (s *IntersightClient) intersightGet(ctx context.Context, uri string) (string, error) {
+	body, _, err := s.intersightGetWithHeaders(ctx, uri)
+	return body, err
+}
+
+func (s *IntersightClient) intersightGetWithHeaders(ctx context.Context, uri string) (string, http.Header, error) {
 
 	s.logger.Debug("Intersight GET request", zap.Any("URI", uri))
 
 	response, err := s.ApiClient.DoGet(
-		*s.AuthCtx,
+		s.requestContext(ctx),
 		uri,
 		"GET",
 		map[string]string{},
@@ -96,34 +133,34 @@ func (s *IntersightClient) intersightGet(uri string) (string, error) {
 
 	if err != nil {
 		s.logger.Error("Error sending GET to Intersight", zap.Error(err))
-		return "", err
+		return "", nil, err
 	}
 	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		s.logger.Error("Error reading response from Intersight", zap.Error(err))
-		return "", err
+		return "", nil, err
 	}
 
 	jsonResponse := map[string]interface{}{}
 	err = json.Unmarshal(body, &jsonResponse)
 	if err != nil {
 		s.logger.Error("Error parsing GET response from Intersight", zap.Error(err))
-		return "", err
+		return "", nil, err
 	}
 
 	// s.logger.Debug("Intersight GET response", zap.Any("GET response body", jsonResponse))
 
-	return string(body), nil
+	return string(body), response.Header, nil
 }
 
-func (s *IntersightClient) intersightPost(uri string, payload *string) (string, error) {
+func (s *IntersightClient) intersightPost(ctx context.Context, uri string, payload *string) (string, error) {
 
 	s.logger.Debug("Intersight POST request", zap.Any("URI", uri), zap.Any("payload", *payload))
 
 	response, err := s.ApiClient.DoPost(
-		*s.AuthCtx,
+		s.requestContext(ctx),
 		uri,
 		"POST",
 		payload,
@@ -0,0 +1,27 @@
+package ciscointersight
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+var queryFiles = []jsonscraper.GoldenQuery{
+	{File: "is-log-alerts.yaml", Signal: "logs"},
+	{File: "is-metric-server-health.yaml", Signal: "metrics"},
+	{File: "is-metric-ts-db-power.yaml", Signal: "metrics"},
+}
+
+func TestQueryFilesGolden(t *testing.T) {
+	for _, q := range queryFiles {
+		q := q
+		t.Run(q.File, func(t *testing.T) {
+			if err := jsonscraper.CompareGolden(q, "../../../conf/is", "testdata/fixtures", "testdata/golden", *update); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
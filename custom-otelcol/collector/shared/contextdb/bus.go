@@ -166,7 +166,7 @@ func (s *ContextSubscriber) HandleMessages(handler func(*ContextData)) {
 	}()
 }
 
-func (s *ContextSubscriber) AttachContextDb(db ContextDb, table string) {
+func (s *ContextSubscriber) AttachContextDb(db *ContextDb, table string) {
 	s.HandleMessages(func(data *ContextData) {
 		record := ContextRecord{
 			Data:              *data,
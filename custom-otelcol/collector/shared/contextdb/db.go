@@ -6,6 +6,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/antchfx/jsonquery"
@@ -213,6 +214,7 @@ type ContextDb struct {
 	Schema *memdb.DBSchema
 	Db     *memdb.MemDB
 	logger *zap.Logger
+	swap   *sync.RWMutex // held for reading by transactions, Migrate replacing Db waits for them
 }
 
 func ParseDbJsonSchema(yamlSchema []byte) (ContextDbSchema, error) {
@@ -307,13 +309,83 @@ func (mdb *ContextDb) Init(schema *memdb.DBSchema, logger *zap.Logger) error {
 	}
 
 	mdb.Db = db
+	mdb.swap = &sync.RWMutex{}
 
 	return nil
 }
 
+// Migration - new schema of the DB with its empty memdb, prepared before the DB is migrated
+type Migration struct {
+	schema *memdb.DBSchema
+	db     *memdb.MemDB
+}
+
+// NewMigration - checks the schema by creating memdb for it, the DB is not changed yet
+func NewMigration(schema *memdb.DBSchema) (*Migration, error) {
+	db, err := memdb.NewMemDB(schema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create memdb with the new schema - %v", err)
+	}
+	return &Migration{schema: schema, db: db}, nil
+}
+
+// Migrate - replaces the schema of the DB, records of tables present in both the old and the new
+// schema are copied. Running transactions are finished first and new ones wait until the records
+// are copied, so no update is lost. The DB keeps the old schema when the records cannot be copied.
+// A migration can be applied once.
+func (mdb *ContextDb) Migrate(migration *Migration, logger *zap.Logger) error {
+	if mdb.Db == nil {
+		return mdb.Init(migration.schema, logger)
+	}
+	schema, db := migration.schema, migration.db
+
+	mdb.swap.Lock()
+	defer mdb.swap.Unlock()
+
+	oldTxn := mdb.Db.Txn(false)
+	defer oldTxn.Abort()
+	txn := db.Txn(true)
+	for tableName := range schema.Tables {
+		if _, ok := mdb.Schema.Tables[tableName]; !ok {
+			continue
+		}
+		iterator, err := oldTxn.Get(tableName, "id")
+		if err != nil {
+			txn.Abort()
+			return fmt.Errorf("cannot read records of table %s - %v", tableName, err)
+		}
+		for obj := iterator.Next(); obj != nil; obj = iterator.Next() {
+			if err := txn.Insert(tableName, obj); err != nil {
+				txn.Abort()
+				return fmt.Errorf("cannot copy record of table %s to the new schema - %v", tableName, err)
+			}
+		}
+	}
+	txn.Commit()
+
+	mdb.Schema = schema
+	mdb.Db = db
+	return nil
+}
+
+// txn - starts transaction on the current memdb, done commits it. Migrate waits until done is
+// called, so the transaction never commits into memdb replaced by a migration.
+func (mdb *ContextDb) txn(write bool) (*memdb.Txn, func()) {
+	if mdb.swap == nil {
+		txn := mdb.Db.Txn(write)
+		return txn, txn.Commit
+	}
+	mdb.swap.RLock()
+	txn := mdb.Db.Txn(write)
+	return txn, func() {
+		txn.Commit()
+		mdb.swap.RUnlock()
+	}
+}
+
 func (mdb *ContextDb) InsertOrUpdateRecord(tableName string, rec *ContextRecord) error {
-	txn := mdb.Db.Txn(true)
-	defer txn.Commit()
+	txn, done := mdb.txn(true)
+	defer done()
 
 	rec.LastUpdatedMillis = time.Now().UnixMilli()
 	err := txn.Insert(tableName, *rec)
@@ -326,8 +398,8 @@ func (mdb *ContextDb) InsertOrUpdateRecord(tableName string, rec *ContextRecord)
 }
 
 func (mdb *ContextDb) GetOneRecord(tableName string, indexName string, fields ...string) (*ContextRecord, error) {
-	txn := mdb.Db.Txn(true)
-	defer txn.Commit()
+	txn, done := mdb.txn(true)
+	defer done()
 
 	fldsAny := []interface{}{}
 	for _, fld := range fields {
@@ -350,8 +422,8 @@ func (mdb *ContextDb) GetOneRecord(tableName string, indexName string, fields ..
 }
 
 func (mdb *ContextDb) GetAllRecords(tableName string, indexName string, fields ...string) ([]ContextRecord, error) {
-	txn := mdb.Db.Txn(false)
-	defer txn.Commit()
+	txn, done := mdb.txn(false)
+	defer done()
 
 	recs := []ContextRecord{}
 	fldsAny := []interface{}{}
@@ -378,8 +450,8 @@ func (mdb *ContextDb) GetAllRecords(tableName string, indexName string, fields .
 }
 
 func (mdb *ContextDb) DeleteRecord(tableName string, record *ContextRecord) error {
-	txn := mdb.Db.Txn(true)
-	defer txn.Commit()
+	txn, done := mdb.txn(true)
+	defer done()
 
 	err := txn.Delete(tableName, record)
 	if err != nil {
@@ -391,14 +463,16 @@ func (mdb *ContextDb) DeleteRecord(tableName string, record *ContextRecord) erro
 
 func (mdb *ContextDb) PurgeRecordsOlderThan(tableName string, ageInMins int) error {
 
-	txn := mdb.Db.Txn(true)
-	defer txn.Commit()
+	txn, done := mdb.txn(true)
+	defer done()
 	txn.Get(tableName, "id")
 	iterator, err := txn.Get(tableName, "id")
 	if err != nil {
 		mdb.logger.Sugar().Infof("Cannot get records in table %s - %v\n", tableName, err)
 		return err
 	}
+	// deleted in the same transaction once iterated, the iterator must not see the changes
+	expired := []ContextRecord{}
 	for obj := iterator.Next(); obj != nil; obj = iterator.Next() {
 		r, ok := obj.(ContextRecord)
 		if !ok {
@@ -409,15 +483,20 @@ func (mdb *ContextDb) PurgeRecordsOlderThan(tableName string, ageInMins int) err
 		if r.LastUpdatedMillis > (time.Now().UnixMilli() - (int64(ageInMins) * 60 * 1000)) {
 			break
 		}
-		mdb.DeleteRecord(tableName, &r)
+		expired = append(expired, r)
+	}
+	for _, r := range expired {
+		if err := txn.Delete(tableName, r); err != nil {
+			mdb.logger.Sugar().Infof("Cannot delete record in table %s, record %v: %v\n", tableName, r, err)
+		}
 	}
 
 	return nil
 }
 
 func (mdb *ContextDb) Dump(tableName string) {
-	txn := mdb.Db.Txn(false)
-	defer txn.Commit()
+	txn, done := mdb.txn(false)
+	defer done()
 	cnt := 0
 	txn.Get(tableName, "id")
 	iterator, err := txn.Get(tableName, "id")
@@ -442,7 +521,7 @@ func (mdb *ContextDb) Dump(tableName string) {
 }
 
 func (mdb *ContextDb) isStillValid(tableName string, indexName string, fldAny []any, rec ContextRecord) bool {
-	table := mdb.Db.DBSchema().Tables[tableName]
+	table := mdb.Db.DBSchema().Tables[tableName] // called within transaction, Db cannot change
 	indexer := table.Indexes[indexName].Indexer
 	indexInternal, err := indexer.FromArgs(fldAny...)
 	if err != nil {
@@ -473,7 +552,7 @@ func (mdb *ContextDb) isStillValid(tableName string, indexName string, fldAny []
 }
 
 func (mdb *ContextDb) removeRedundancies(tableName string, recs []ContextRecord) []ContextRecord {
-	table := mdb.Db.DBSchema().Tables[tableName]
+	table := mdb.Db.DBSchema().Tables[tableName] // called within transaction, Db cannot change
 	idIndexer := table.Indexes["id"].Indexer.(*SingleValueFieldIndexer)
 
 	idIndexMap := map[string]bool{}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/antchfx/jsonquery"
 	"go.uber.org/zap"
@@ -78,3 +80,115 @@ func Test(t *testing.T) {
 	t.Logf("")
 
 }
+
+func TestMigrate(t *testing.T) {
+	schemaConfig, err := os.ReadFile(SCHEMA_FILE)
+	if err != nil {
+		t.Fatalf("cannot read db schema yaml %s - %v", SCHEMA_FILE, err)
+	}
+	dbSchemaAbstract, err := ParseDbJsonSchema(schemaConfig)
+	if err != nil {
+		t.Fatalf("cannot parse db schema file %s - %v", SCHEMA_FILE, err)
+	}
+	dbSchema, err := GetDbSchema(dbSchemaAbstract)
+	if err != nil {
+		t.Fatalf("cannot convert schema to memdb schema %s - %v", SCHEMA_FILE, err)
+	}
+	db := ContextDb{}
+	if err = db.Init(dbSchema, zap.NewNop()); err != nil {
+		t.Fatalf("cannot init DB %s - %v", SCHEMA_FILE, err)
+	}
+	for _, a := range appdData {
+		jsonDoc, _ := json.Marshal(a)
+		jsonQueryDoc, _ := jsonquery.Parse(bytes.NewReader(jsonDoc))
+		db.InsertOrUpdateRecord("appd", &ContextRecord{Data: jsonQueryDoc})
+	}
+
+	// appd table stays, k8s-pods is dropped and nodes added
+	newSchemaAbstract, err := ParseDbJsonSchema([]byte(`
+schemas:
+- name: appd
+  indexes:
+  - name: id
+    unique: true
+    fields: ["/application","/tier","/node"]
+- name: nodes
+  indexes:
+  - name: id
+    unique: true
+    fields: ["/name"]
+`))
+	if err != nil {
+		t.Fatalf("cannot parse new schema - %v", err)
+	}
+	newSchema, err := GetDbSchema(newSchemaAbstract)
+	if err != nil {
+		t.Fatalf("cannot convert new schema - %v", err)
+	}
+	migration, err := NewMigration(newSchema)
+	if err != nil {
+		t.Fatalf("cannot prepare migration - %v", err)
+	}
+	if err = db.Migrate(migration, zap.NewNop()); err != nil {
+		t.Fatalf("cannot migrate DB - %v", err)
+	}
+
+	rec, err := db.GetOneRecord("appd", "id", "Mockup-Cont", "Cont-Tier-2", "cont4")
+	if err != nil || rec == nil {
+		t.Fatalf("expected record to survive migration - %v", err)
+	}
+	if _, err = db.GetOneRecord("k8s-pods", "id", "pod"); err == nil {
+		t.Fatalf("expected dropped table to be gone")
+	}
+	if _, err = db.GetAllRecords("nodes", "id", ""); err != nil {
+		t.Fatalf("expected new table - %v", err)
+	}
+}
+
+func TestMigrateWaitsForWrites(t *testing.T) {
+	schemaConfig, err := os.ReadFile(SCHEMA_FILE)
+	if err != nil {
+		t.Fatalf("cannot read db schema yaml %s - %v", SCHEMA_FILE, err)
+	}
+	dbSchemaAbstract, err := ParseDbJsonSchema(schemaConfig)
+	if err != nil {
+		t.Fatalf("cannot parse db schema file %s - %v", SCHEMA_FILE, err)
+	}
+	dbSchema, err := GetDbSchema(dbSchemaAbstract)
+	if err != nil {
+		t.Fatalf("cannot convert schema to memdb schema %s - %v", SCHEMA_FILE, err)
+	}
+	db := ContextDb{}
+	if err = db.Init(dbSchema, zap.NewNop()); err != nil {
+		t.Fatalf("cannot init DB %s - %v", SCHEMA_FILE, err)
+	}
+	migration, err := NewMigration(dbSchema)
+	if err != nil {
+		t.Fatalf("cannot prepare migration - %v", err)
+	}
+
+	// write transaction of a subscriber is open when the migration starts
+	txn, done := db.txn(true)
+	migrated := make(chan error)
+	go func() {
+		migrated <- db.Migrate(migration, zap.NewNop())
+	}()
+	doc, _ := jsonquery.Parse(strings.NewReader(`{"application":"App","tier":"Tier","node":"node1"}`))
+	if err = txn.Insert("appd", ContextRecord{Data: doc}); err != nil {
+		t.Fatalf("cannot insert record - %v", err)
+	}
+	select {
+	case err = <-migrated:
+		t.Fatalf("migration did not wait for the open transaction - %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	done()
+	if err = <-migrated; err != nil {
+		t.Fatalf("cannot migrate DB - %v", err)
+	}
+
+	rec, err := db.GetOneRecord("appd", "id", "App", "Tier", "node1")
+	if err != nil || rec == nil {
+		t.Fatalf("expected record written during migration to survive - %v", err)
+	}
+}
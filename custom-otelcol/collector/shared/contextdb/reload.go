@@ -0,0 +1,71 @@
+package contextdb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/go-memdb"
+	"go.uber.org/zap"
+)
+
+// LoadDbSchema - reads tableSchemas files, nil when there are none
+func LoadDbSchema(schemaFiles []string) (*memdb.DBSchema, error) {
+	dbJsonSchemas := []*ContextTableSchema{}
+
+	for _, schema := range schemaFiles {
+		schemaConfig, err := os.ReadFile(schema)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read db schema yaml %s - %v", schema, err)
+		}
+
+		dbJsonSchema, err := ParseDbJsonSchema(schemaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse db schema file %s - %v", schema, err)
+		}
+
+		dbJsonSchemas = AppendDbJsonSchema(dbJsonSchemas, dbJsonSchema)
+	}
+
+	if len(dbJsonSchemas) == 0 {
+		return nil, nil
+	}
+	dbSchema, err := GetDbSchema(dbJsonSchemas)
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert schema to memdb schema %v - %v", dbSchema, err)
+	}
+	return dbSchema, nil
+}
+
+// ReloadMigration - prepares migration of the DB to the schemas when any of them is among the changed
+// files. The returned function applies it, e.g. within the scraper's reload, nil when no schema changed.
+func (mdb *ContextDb) ReloadMigration(schemaFiles []string, changed []string, logger *zap.Logger) (func() error, error) {
+	changedFiles := map[string]bool{}
+	for _, file := range changed {
+		changedFiles[file] = true
+	}
+	schemaChanged := false
+	for _, schema := range schemaFiles {
+		schemaChanged = schemaChanged || changedFiles[schema]
+	}
+	if !schemaChanged {
+		return nil, nil
+	}
+
+	dbSchema, err := LoadDbSchema(schemaFiles)
+	if err != nil {
+		return nil, err
+	}
+	if dbSchema == nil {
+		return nil, nil
+	}
+	migration, err := NewMigration(dbSchema)
+	if err != nil {
+		return nil, fmt.Errorf("cannot migrate Context DB - %v", err)
+	}
+	return func() error {
+		if err := mdb.Migrate(migration, logger); err != nil {
+			return fmt.Errorf("cannot migrate Context DB - %v", err)
+		}
+		return nil
+	}, nil
+}
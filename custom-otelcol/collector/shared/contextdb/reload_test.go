@@ -0,0 +1,60 @@
+package contextdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestReloadMigration(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.yaml")
+	schemaConfig, err := os.ReadFile(SCHEMA_FILE)
+	if err != nil {
+		t.Fatalf("cannot read db schema yaml %s - %v", SCHEMA_FILE, err)
+	}
+	os.WriteFile(schemaFile, schemaConfig, 0o644)
+
+	dbSchema, err := LoadDbSchema([]string{schemaFile})
+	if err != nil || dbSchema == nil {
+		t.Fatalf("cannot load db schema - %v", err)
+	}
+	db := ContextDb{}
+	if err = db.Init(dbSchema, zap.NewNop()); err != nil {
+		t.Fatalf("cannot init DB - %v", err)
+	}
+
+	migrate, err := db.ReloadMigration([]string{schemaFile}, []string{filepath.Join(dir, "queries.yaml")}, zap.NewNop())
+	if err != nil || migrate != nil {
+		t.Fatalf("expected no migration when no schema changed - %v", err)
+	}
+
+	os.WriteFile(schemaFile, []byte(`
+schemas:
+- name: nodes
+  indexes:
+  - name: id
+    unique: true
+    fields: ["/name"]
+`), 0o644)
+	migrate, err = db.ReloadMigration([]string{schemaFile}, []string{schemaFile}, zap.NewNop())
+	if err != nil || migrate == nil {
+		t.Fatalf("expected migration of changed schema - %v", err)
+	}
+	if _, ok := db.Schema.Tables["nodes"]; ok {
+		t.Fatalf("expected the DB to keep its schema until the migration is applied")
+	}
+	if err = migrate(); err != nil {
+		t.Fatalf("cannot migrate DB - %v", err)
+	}
+	if _, ok := db.Schema.Tables["nodes"]; !ok {
+		t.Fatalf("expected the new table after migration, tables %v", db.Schema.Tables)
+	}
+
+	os.WriteFile(schemaFile, []byte("schemas: [[]"), 0o644)
+	if _, err = db.ReloadMigration([]string{schemaFile}, []string{schemaFile}, zap.NewNop()); err == nil {
+		t.Fatalf("expected invalid schema to be rejected")
+	}
+}
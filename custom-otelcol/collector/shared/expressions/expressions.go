@@ -18,10 +18,12 @@ type ExpressionEnvironment struct {
 	env             *cel.Env
 	expressionCache map[string]*cel.Program
 	duplicatesCache map[string]time.Time
-	reducers        map[string][]ref.Val
+	reducers        *Reducers // used when the expression is evaluated without reducers of a rule
+	scopeReducers   *Reducers // reducers of the rule the expression is evaluated in, set under mutex
 	Logger          *zap.Logger
 	JqDoc           *jsonquery.Node
 	db              *contextdb.ContextDb
+	clock           func() time.Time // time of now(), time.Now when nil
 	mutex           sync.Mutex
 }
 
@@ -53,7 +55,7 @@ func (c *ExpressionEnvironment) InitEnv(logger *zap.Logger, db *contextdb.Contex
 	envOptions = append(envOptions, pathParseMemberFunction)
 	envOptions = append(envOptions, stringTimeToUnixMillisFunction)
 	envOptions = append(envOptions, stringTimeToUnixMillisMemberFunction)
-	envOptions = append(envOptions, stringTimeNow)
+	envOptions = append(envOptions, c.timeFunctions()...)
 	envOptions = append(envOptions, millisTimeToStringFunction)
 	envOptions = append(envOptions, millisTimeToStringMemberFunction)
 	envOptions = append(envOptions, stringGrokFunction)
@@ -71,7 +73,7 @@ func (c *ExpressionEnvironment) InitEnv(logger *zap.Logger, db *contextdb.Contex
 	c.env = env
 	c.expressionCache = map[string]*cel.Program{}
 	c.duplicatesCache = map[string]time.Time{}
-	c.reducers = map[string][]ref.Val{}
+	c.reducers = NewReducers(nil)
 	c.db = db
 	c.Logger = logger
 
@@ -123,6 +125,12 @@ func (c *ExpressionEnvironment) CompileExpression(expr string) (*cel.Program, er
 	return &prg, nil
 }
 
+// CheckExpression - parses and type checks the expression without building a program, for config validation
+func (c *ExpressionEnvironment) CheckExpression(expr string) error {
+	_, issues := c.env.Compile(expr)
+	return issues.Err()
+}
+
 func (c *ExpressionEnvironment) EvaluateExpression(expr string, args map[string]interface{}) (*ref.Val, error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -152,9 +160,19 @@ func (c *ExpressionEnvironment) EvaluateExpression(expr string, args map[string]
 }
 
 func (c *ExpressionEnvironment) EvaluateExpressionWithJqDoc(doc *jsonquery.Node, expr string, bindings map[string]interface{}) (any, error) {
+	return c.EvaluateExpressionWithReducers(doc, nil, expr, bindings)
+}
+
+// EvaluateExpressionWithReducers - like EvaluateExpressionWithJqDoc, reducerMap and reducerGroups
+// read the given reducers, nil means reducers of the environment
+func (c *ExpressionEnvironment) EvaluateExpressionWithReducers(doc *jsonquery.Node, reducers *Reducers, expr string, bindings map[string]interface{}) (any, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.JqSetDoc(doc)
+	c.scopeReducers = reducers
+	defer func() {
+		c.scopeReducers = nil
+	}()
 	val, err := c.EvaluateExpression(expr, bindings)
 	if err != nil {
 		return "", err
@@ -1,7 +1,10 @@
 package expressions
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"sync"
 
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/types"
@@ -9,37 +12,143 @@ import (
 	"github.com/google/cel-go/common/types/traits"
 )
 
-func (c *ExpressionEnvironment) InitReducerMap(reducerName string) {
-	c.reducers[reducerName] = []ref.Val{}
+// Reducers - reducer maps declared by one rule invocation. Values are added by the rule's
+// children and forEach items, possibly in parallel, and grouped by the groupBy key. Names not
+// declared here are looked up in the parent, i.e. the reducers of the enclosing rules.
+type Reducers struct {
+	parent *Reducers
+	mutex  sync.Mutex
+	maps   map[string]map[string][]ref.Val // reducer name -> group key -> values
 }
 
-func (c *ExpressionEnvironment) GetReducerMap(reducerName string) []ref.Val {
-	reducerMap, ok := c.reducers[reducerName]
-	if !ok {
-		reducerMap = []ref.Val{}
+// NewReducers - declares empty reducer maps of a rule, parent may be nil
+func NewReducers(parent *Reducers, names ...string) *Reducers {
+	r := &Reducers{
+		parent: parent,
+		maps:   map[string]map[string][]ref.Val{},
 	}
-	return reducerMap
+	for _, name := range names {
+		r.maps[name] = map[string][]ref.Val{}
+	}
+	return r
 }
 
-func (c *ExpressionEnvironment) AddValueToReducerMap(reducerName string, value any) error {
-	reducerMap, ok := c.reducers[reducerName]
-	if !ok {
-		reducerMap = []ref.Val{}
+// Declares - whether the reducer map is declared here, not in the parent
+func (r *Reducers) Declares(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, ok := r.maps[name]
+	return ok
+}
+
+// declaring - returns reducers declaring the name, nil if it is not declared
+func (r *Reducers) declaring(name string) *Reducers {
+	for curr := r; curr != nil; curr = curr.parent {
+		curr.mutex.Lock()
+		_, ok := curr.maps[name]
+		curr.mutex.Unlock()
+		if ok {
+			return curr
+		}
 	}
-	rt := reflect.TypeOf(value)
-	switch rt.Kind() {
-	case reflect.Slice:
-		for _, val := range value.([]any) {
-			reducerMap = append(reducerMap, c.anyScalarToCelType(val))
+	return nil
+}
+
+// Add - adds value to the group of the reducer map, a list adds each of its items
+func (r *Reducers) Add(name string, group string, value any) error {
+	declaring := r.declaring(name)
+	if declaring == nil {
+		return fmt.Errorf("reducer %s is not declared", name)
+	}
+	values := []ref.Val{}
+	if list, ok := value.([]any); ok {
+		for _, val := range list {
+			values = append(values, anyScalarToCelType(val))
 		}
+	} else {
+		values = append(values, anyScalarToCelType(value))
+	}
 
-	default:
-		reducerMap = append(reducerMap, c.anyScalarToCelType(value))
+	declaring.mutex.Lock()
+	defer declaring.mutex.Unlock()
+	groups := declaring.maps[name]
+	groups[group] = append(groups[group], values...)
+	return nil
+}
+
+// Values - returns values of all groups of the reducer map, groups sorted by their key
+func (r *Reducers) Values(name string) []ref.Val {
+	values := []ref.Val{}
+	groups := r.Groups(name)
+	for _, key := range sortedKeys(groups) {
+		values = append(values, groups[key]...)
 	}
+	return values
+}
 
-	c.reducers[reducerName] = reducerMap
+// GroupKeys - returns sorted group keys of the reducer map, values added without groupBy
+// have empty key
+func (r *Reducers) GroupKeys(name string) []string {
+	return sortedKeys(r.Groups(name))
+}
 
-	return nil
+// Groups - returns copy of values of the reducer map by group key
+func (r *Reducers) Groups(name string) map[string][]ref.Val {
+	groups := map[string][]ref.Val{}
+	declaring := r.declaring(name)
+	if declaring == nil {
+		return groups
+	}
+	declaring.mutex.Lock()
+	defer declaring.mutex.Unlock()
+	for key, values := range declaring.maps[name] {
+		groups[key] = append([]ref.Val{}, values...)
+	}
+	return groups
+}
+
+// Group - returns reducers where the reducer map has only values of one group, used by emits
+// iterating the groups
+func (r *Reducers) Group(name string, key string) *Reducers {
+	group := NewReducers(r, name)
+	group.maps[name][key] = r.Groups(name)[key]
+	return group
+}
+
+func sortedKeys(groups map[string][]ref.Val) []string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// InitReducerMap - declares empty reducer map of the environment, used when expressions
+// are evaluated without reducers of a rule
+func (c *ExpressionEnvironment) InitReducerMap(reducerName string) {
+	c.reducers.mutex.Lock()
+	defer c.reducers.mutex.Unlock()
+	c.reducers.maps[reducerName] = map[string][]ref.Val{}
+}
+
+func (c *ExpressionEnvironment) GetReducerMap(reducerName string) []ref.Val {
+	return c.reducers.Values(reducerName)
+}
+
+func (c *ExpressionEnvironment) AddValueToReducerMap(reducerName string, value any) error {
+	if c.reducers.declaring(reducerName) == nil {
+		c.InitReducerMap(reducerName)
+	}
+	return c.reducers.Add(reducerName, "", value)
+}
+
+// currentReducers - reducers of the expression being evaluated, or of the environment
+func (c *ExpressionEnvironment) currentReducers() *Reducers {
+	if c.scopeReducers != nil {
+		return c.scopeReducers
+	}
+	return c.reducers
 }
 
 func (c *ExpressionEnvironment) anyToCelType(value any) ref.Val {
@@ -48,15 +157,15 @@ func (c *ExpressionEnvironment) anyToCelType(value any) ref.Val {
 	case reflect.Slice:
 		parts := []ref.Val{}
 		for _, val := range value.([]any) {
-			parts = append(parts, c.anyScalarToCelType(val))
+			parts = append(parts, anyScalarToCelType(val))
 		}
 		return types.NewRefValList(AnyAdapter{}, parts)
 	default:
-		return c.anyScalarToCelType(value)
+		return anyScalarToCelType(value)
 	}
 }
 
-func (c *ExpressionEnvironment) anyScalarToCelType(value any) ref.Val {
+func anyScalarToCelType(value any) ref.Val {
 	switch value.(type) {
 	case float64:
 		return types.Double(value.(float64))
@@ -85,14 +194,15 @@ func (c *ExpressionEnvironment) reducerFunctions() []cel.EnvOption {
 		result := 0.0
 		values, ok := args[0].(traits.Lister)
 		if !ok {
-			return types.NewErr("invalid operand of type '%v' - should be a list of double", args[0].Type())
+			return types.NewErr("invalid operand of type '%v' - should be a list of numbers", args[0].Type())
 		}
 
 		iter := values.Iterator()
 		for iter.HasNext().Value().(bool) {
-			i := iter.Next()
-			// fmt.Printf("Got here: %v\n", args)
-			value := i.Value().(float64)
+			value, ok := reducerNumber(iter.Next())
+			if !ok {
+				return types.NewErr("invalid value in list - should be a number")
+			}
 			result += value
 		}
 
@@ -103,7 +213,7 @@ func (c *ExpressionEnvironment) reducerFunctions() []cel.EnvOption {
 
 		values, ok := args[0].(traits.Lister)
 		if !ok {
-			return types.NewErr("invalid operand of type '%v' - should be a list of double", args[0].Type())
+			return types.NewErr("invalid operand of type '%v' - should be a list of numbers", args[0].Type())
 		}
 
 		return values.Size()
@@ -115,14 +225,15 @@ func (c *ExpressionEnvironment) reducerFunctions() []cel.EnvOption {
 		counter := 0.0
 		values, ok := args[0].(traits.Lister)
 		if !ok {
-			return types.NewErr("invalid operand of type '%v' - should be a list of double", args[0].Type())
+			return types.NewErr("invalid operand of type '%v' - should be a list of numbers", args[0].Type())
 		}
 
 		iter := values.Iterator()
 		for iter.HasNext().Value().(bool) {
-			i := iter.Next()
-			// fmt.Printf("Got here: %v\n", args)
-			value := i.Value().(float64)
+			value, ok := reducerNumber(iter.Next())
+			if !ok {
+				return types.NewErr("invalid value in list - should be a number")
+			}
 			accum += value
 			counter++
 		}
@@ -134,6 +245,83 @@ func (c *ExpressionEnvironment) reducerFunctions() []cel.EnvOption {
 		return types.Double(result)
 	})
 
+	// min and max have no value for an empty list, the expression fails then
+	var extremeReducerFunctionImpl = func(less func(a float64, b float64) bool) cel.OverloadOpt {
+		return cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+			values, ok := args[0].(traits.Lister)
+			if !ok {
+				return types.NewErr("invalid operand of type '%v' - should be a list of numbers", args[0].Type())
+			}
+			if values.Size().Value().(int64) == 0 {
+				return types.NewErr("no values to reduce")
+			}
+
+			result := 0.0
+			iter := values.Iterator()
+			for first := true; iter.HasNext().Value().(bool); first = false {
+				value, ok := reducerNumber(iter.Next())
+				if !ok {
+					return types.NewErr("invalid value in list - should be a number")
+				}
+				if first || less(value, result) {
+					result = value
+				}
+			}
+
+			return types.Double(result)
+		})
+	}
+
+	var minReducerFunctionImpl = extremeReducerFunctionImpl(func(a float64, b float64) bool { return a < b })
+	var maxReducerFunctionImpl = extremeReducerFunctionImpl(func(a float64, b float64) bool { return a > b })
+
+	var lastReducerFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+		values, ok := args[0].(traits.Lister)
+		if !ok {
+			return types.NewErr("invalid operand of type '%v' - should be a list", args[0].Type())
+		}
+		size := values.Size().Value().(int64)
+		if size == 0 {
+			return types.NewErr("no values to reduce")
+		}
+
+		return values.Get(types.Int(size - 1))
+	})
+
+	var distinctCountReducerFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+		values, ok := args[0].(traits.Lister)
+		if !ok {
+			return types.NewErr("invalid operand of type '%v' - should be a list", args[0].Type())
+		}
+
+		distinct := map[any]bool{}
+		iter := values.Iterator()
+		for iter.HasNext().Value().(bool) {
+			value := iter.Next().Value()
+			if number, ok := value.(int64); ok {
+				value = float64(number) // 1 and 1.0 are the same value
+			}
+			distinct[fmt.Sprintf("%T:%v", value, value)] = true
+		}
+
+		return types.Int(len(distinct))
+	})
+
+	var reducerGroupsFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+
+		reducerName, ok := args[0].Value().(string)
+		if !ok {
+			return types.NewErr("invalid operand of type '%v' - should be a string", args[0].Type())
+		}
+
+		groups := map[string]any{}
+		for key, values := range c.currentReducers().Groups(reducerName) {
+			groups[key] = types.NewDynamicList(AnyAdapter{}, values)
+		}
+
+		return types.NewStringInterfaceMap(AnyAdapter{}, groups)
+	})
+
 	var reducerMapFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
 
 		reducerName, ok := args[0].Value().(string)
@@ -141,61 +329,125 @@ func (c *ExpressionEnvironment) reducerFunctions() []cel.EnvOption {
 			return types.NewErr("invalid operand of type '%v' - should be a string", args[0].Type())
 		}
 
-		reducerMap := c.GetReducerMap(reducerName)
+		reducerMap := c.currentReducers().Values(reducerName)
 
 		return types.NewDynamicList(AnyAdapter{}, reducerMap)
 	})
 
 	var sumReducerFunction = cel.Function("sumReducer",
-		cel.Overload("sumReducer_list_double",
-			[]*cel.Type{cel.ListType(cel.DoubleType)},
+		cel.Overload("sumReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
 			cel.DoubleType,
 			sumReducerFunctionImpl,
 		),
 	)
 
 	var sumReducerMemberFunction = cel.Function("sumReducer",
-		cel.MemberOverload("list_sumReducer_double",
-			[]*cel.Type{cel.ListType(cel.DoubleType)},
+		cel.MemberOverload("list_sumReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
 			cel.DoubleType,
 			sumReducerFunctionImpl,
 		),
 	)
 
 	var countReducerFunction = cel.Function("countReducer",
-		cel.Overload("countReducer_list_double",
-			[]*cel.Type{cel.ListType(cel.DoubleType)},
+		cel.Overload("countReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
 			cel.IntType,
 			countReducerFunctionImpl,
 		),
 	)
 
 	var countReducerMemberFunction = cel.Function("countReducer",
-		cel.MemberOverload("list_countReducer_double",
-			[]*cel.Type{cel.ListType(cel.DoubleType)},
+		cel.MemberOverload("list_countReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
 			cel.IntType,
 			countReducerFunctionImpl,
 		),
 	)
 
 	var avgReducerFunction = cel.Function("avgReducer",
-		cel.Overload("avgReducer_list_double",
-			[]*cel.Type{cel.ListType(cel.DoubleType)},
+		cel.Overload("avgReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
 			cel.DoubleType,
 			avgReducerFunctionImpl,
 		),
 	)
 
 	var avgReducerMemberFunction = cel.Function("avgReducer",
-		cel.MemberOverload("list_avgReducer_double",
-			[]*cel.Type{cel.ListType(cel.DoubleType)},
+		cel.MemberOverload("list_avgReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
 			cel.DoubleType,
 			avgReducerFunctionImpl,
 		),
 	)
 
+	var minReducerFunction = cel.Function("minReducer",
+		cel.Overload("minReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.DoubleType,
+			minReducerFunctionImpl,
+		),
+	)
+
+	var minReducerMemberFunction = cel.Function("minReducer",
+		cel.MemberOverload("list_minReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.DoubleType,
+			minReducerFunctionImpl,
+		),
+	)
+
+	var maxReducerFunction = cel.Function("maxReducer",
+		cel.Overload("maxReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.DoubleType,
+			maxReducerFunctionImpl,
+		),
+	)
+
+	var maxReducerMemberFunction = cel.Function("maxReducer",
+		cel.MemberOverload("list_maxReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.DoubleType,
+			maxReducerFunctionImpl,
+		),
+	)
+
+	var lastReducerFunction = cel.Function("lastReducer",
+		cel.Overload("lastReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.AnyType,
+			lastReducerFunctionImpl,
+		),
+	)
+
+	var lastReducerMemberFunction = cel.Function("lastReducer",
+		cel.MemberOverload("list_lastReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.AnyType,
+			lastReducerFunctionImpl,
+		),
+	)
+
+	var distinctCountReducerFunction = cel.Function("distinctCountReducer",
+		cel.Overload("distinctCountReducer_list_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.IntType,
+			distinctCountReducerFunctionImpl,
+		),
+	)
+
+	var distinctCountReducerMemberFunction = cel.Function("distinctCountReducer",
+		cel.MemberOverload("list_distinctCountReducer_any",
+			[]*cel.Type{cel.ListType(cel.AnyType)},
+			cel.IntType,
+			distinctCountReducerFunctionImpl,
+		),
+	)
+
 	// var joinReducerMemberFunction = cel.Function("joinReducer",
-	// 	cel.MemberOverload("list_avgReducer_double",
+	// 	cel.MemberOverload("list_avgReducer_any",
 	// 		[]*cel.Type{cel.ListType(cel.DoubleType)},
 	// 		cel.DoubleType,
 	// 		avgReducerFunctionImpl,
@@ -210,13 +462,43 @@ func (c *ExpressionEnvironment) reducerFunctions() []cel.EnvOption {
 		),
 	)
 
+	var reducerGroupsFunction = cel.Function("reducerGroups",
+		cel.Overload("reducerGroups_string_map",
+			[]*cel.Type{cel.StringType},
+			cel.MapType(cel.StringType, cel.ListType(cel.AnyType)),
+			reducerGroupsFunctionImpl,
+		),
+	)
+
 	functions = append(functions, sumReducerFunction)
 	functions = append(functions, countReducerFunction)
 	functions = append(functions, avgReducerFunction)
 	functions = append(functions, sumReducerMemberFunction)
 	functions = append(functions, countReducerMemberFunction)
 	functions = append(functions, avgReducerMemberFunction)
+	functions = append(functions, minReducerFunction)
+	functions = append(functions, maxReducerFunction)
+	functions = append(functions, lastReducerFunction)
+	functions = append(functions, distinctCountReducerFunction)
+	functions = append(functions, minReducerMemberFunction)
+	functions = append(functions, maxReducerMemberFunction)
+	functions = append(functions, lastReducerMemberFunction)
+	functions = append(functions, distinctCountReducerMemberFunction)
 	functions = append(functions, reducerMapFunction)
+	functions = append(functions, reducerGroupsFunction)
 
 	return functions
 }
+
+// reducerNumber - returns value of the list item as float64, reducer maps keep ints and doubles
+func reducerNumber(value ref.Val) (float64, bool) {
+	switch number := value.Value().(type) {
+	case float64:
+		return number, true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	}
+	return 0, false
+}
@@ -1,6 +1,10 @@
 package expressions
 
-import "testing"
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
 
 func TestReducerSumFunc(t *testing.T) {
 	REDUCER_NAME := "testReducer"
@@ -113,3 +117,127 @@ func TestReducerAvgFunc(t *testing.T) {
 		t.Fatalf("expected: %v != actual: %v", expect, sum)
 	}
 }
+
+func TestReducerMinMaxLastDistinctFunc(t *testing.T) {
+	REDUCER_NAME := "testReducer"
+	env := ExpressionEnvironment{}
+	err := env.InitEnv(env.initLogger("debug"), nil)
+	if err != nil {
+		t.Fatalf("Cannot initialize expressions - %v", err)
+	}
+
+	args := map[string]interface{}{}
+
+	env.InitReducerMap(REDUCER_NAME)
+	env.AddValueToReducerMap(REDUCER_NAME, 8.0)
+	env.AddValueToReducerMap(REDUCER_NAME, 4.0)
+	env.AddValueToReducerMap(REDUCER_NAME, 10.0)
+	env.AddValueToReducerMap(REDUCER_NAME, 4.0)
+	tests := map[string]any{
+		`minReducer(reducerMap("` + REDUCER_NAME + `"))`:           4.0,
+		`reducerMap("` + REDUCER_NAME + `").maxReducer()`:          10.0,
+		`reducerMap("` + REDUCER_NAME + `").lastReducer()`:         4.0,
+		`distinctCountReducer(reducerMap("` + REDUCER_NAME + `"))`: int64(3),
+	}
+	for expression, expect := range tests {
+		ret, err := env.EvaluateExpression(expression, args)
+		if err != nil {
+			t.Fatalf("Cannot evaluate %s - %v", expression, err)
+		}
+		if (*ret).Value() != expect {
+			t.Fatalf("%s expected: %v != actual: %v", expression, expect, (*ret).Value())
+		}
+	}
+
+	if _, err := env.EvaluateExpression(`reducerMap("empty").minReducer()`, args); err == nil {
+		t.Fatalf("expected min of empty reducer map to fail")
+	}
+}
+
+func TestReducersScopeAndGroups(t *testing.T) {
+	env := ExpressionEnvironment{}
+	err := env.InitEnv(env.initLogger("debug"), nil)
+	if err != nil {
+		t.Fatalf("Cannot initialize expressions - %v", err)
+	}
+
+	outer := NewReducers(nil, "power")
+	inner := NewReducers(outer, "nodes")
+	other := NewReducers(nil, "power")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pod := fmt.Sprintf("pod-%d", i%2+1)
+			inner.Add("power", pod, float64(i))
+			inner.Add("nodes", "", []any{fmt.Sprintf("node-%d", i%10), "spine"})
+			other.Add("power", "", 1.0)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := inner.Add("missing", "", 1.0); err == nil {
+		t.Fatalf("expected adding to undeclared reducer to fail")
+	}
+	if keys := outer.GroupKeys("power"); len(keys) != 2 || keys[0] != "pod-1" || keys[1] != "pod-2" {
+		t.Fatalf("unexpected groups %v", keys)
+	}
+
+	tests := []struct {
+		reducers *Reducers
+		expr     string
+		expect   any
+	}{
+		{inner, `reducerMap("power").sumReducer()`, 4950.0},
+		{other, `reducerMap("power").sumReducer()`, 100.0},
+		{inner, `reducerGroups("power")["pod-1"].sumReducer()`, 2450.0},
+		{inner.Group("power", "pod-2"), `reducerMap("power").sumReducer()`, 2500.0},
+		{inner.Group("power", "pod-2"), `reducerMap("nodes").distinctCountReducer()`, int64(11)},
+		{nil, `reducerMap("power").countReducer()`, int64(0)},
+	}
+	for _, test := range tests {
+		value, err := env.EvaluateExpressionWithReducers(nil, test.reducers, test.expr, map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("Cannot evaluate %s - %v", test.expr, err)
+		}
+		if value != test.expect {
+			t.Fatalf("%s expected: %v != actual: %v", test.expr, test.expect, value)
+		}
+	}
+}
+
+func TestReducerIntValues(t *testing.T) {
+	reducers := NewReducers(nil, "mixed", "text")
+	reducers.Add("mixed", "", 4)
+	reducers.Add("mixed", "", int64(6))
+	reducers.Add("mixed", "", 5.0)
+	reducers.Add("text", "", "leaf1")
+
+	env := ExpressionEnvironment{}
+	err := env.InitEnv(env.initLogger("debug"), nil)
+	if err != nil {
+		t.Fatalf("Cannot initialize expressions - %v", err)
+	}
+	tests := map[string]any{
+		`reducerMap("mixed").sumReducer()`: 15.0,
+		`reducerMap("mixed").avgReducer()`: 5.0,
+		`reducerMap("mixed").minReducer()`: 4.0,
+	}
+	for expression, expect := range tests {
+		value, err := env.EvaluateExpressionWithReducers(nil, reducers, expression, map[string]interface{}{})
+		if err != nil {
+			t.Fatalf("Cannot evaluate %s - %v", expression, err)
+		}
+		if value != expect {
+			t.Fatalf("%s expected: %v != actual: %v", expression, expect, value)
+		}
+	}
+
+	for _, expression := range []string{`reducerMap("text").sumReducer()`, `reducerMap("text").avgReducer()`} {
+		if _, err := env.EvaluateExpressionWithReducers(nil, reducers, expression, map[string]interface{}{}); err == nil {
+			t.Fatalf("expected %s of strings to fail", expression)
+		}
+	}
+}
@@ -24,14 +24,6 @@ var stringTimeToUnixMillisMemberFunction = cel.Function("toUnixMillis",
 	),
 )
 
-var stringTimeNow = cel.Function("now",
-	cel.Overload("now",
-		[]*cel.Type{},
-		cel.StringType,
-		nowFunctionImpl,
-	),
-)
-
 var millisTimeToStringFunction = cel.Function("fromUnixMillis",
 	cel.Overload("fromUnixMillis_int_string",
 		[]*cel.Type{cel.IntType},
@@ -66,15 +58,41 @@ var toUnixMillisFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val
 	return types.Int(result)
 })
 
-var nowFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
-	result := time.Now().Format(time.RFC3339)
-
-	return types.String(result)
-})
-
 var fromUnixMillisImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
 	inputTime := time.UnixMilli(args[0].Value().(int64))
 	result := inputTime.Format(time.RFC3339)
 
 	return types.String(result)
 })
+
+// timeFunctions - now() reads the clock of the environment, time.Now unless SetClock replaced it
+func (c *ExpressionEnvironment) timeFunctions() []cel.EnvOption {
+	var nowFunctionImpl = cel.FunctionBinding(func(args ...ref.Val) ref.Val {
+		result := c.now().Format(time.RFC3339)
+
+		return types.String(result)
+	})
+
+	var stringTimeNow = cel.Function("now",
+		cel.Overload("now",
+			[]*cel.Type{},
+			cel.StringType,
+			nowFunctionImpl,
+		),
+	)
+
+	return []cel.EnvOption{stringTimeNow}
+}
+
+// SetClock - replaces time.Now used by now(), e.g. to replay recorded data at the time of recording,
+// nil restores time.Now
+func (c *ExpressionEnvironment) SetClock(clock func() time.Time) {
+	c.clock = clock
+}
+
+func (c *ExpressionEnvironment) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
@@ -72,6 +72,24 @@ func TestTimeNow(t *testing.T) {
 	}
 }
 
+func TestTimeNowClock(t *testing.T) {
+	env := ExpressionEnvironment{}
+	err := env.InitEnv(env.initLogger("debug"), nil)
+	if err != nil {
+		t.Fatalf("Cannot init environment - %v", err)
+	}
+	recorded := time.Date(2024, 5, 14, 8, 13, 5, 0, time.UTC)
+	env.SetClock(func() time.Time { return recorded })
+
+	ret, err := env.EvaluateExpression(`now().toUnixMillis()`, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Cannot compile test expression - %v", err)
+	}
+	if millis := (*ret).Value().(int64); millis != recorded.UnixMilli() {
+		t.Fatalf("expected: %v != actual: %v", recorded.UnixMilli(), millis)
+	}
+}
+
 func TestFromUnixMillisFunc(t *testing.T) {
 	env := ExpressionEnvironment{}
 	err := env.InitEnv(env.initLogger("debug"), nil)
@@ -0,0 +1,188 @@
+package jsonscraper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/jsonquery"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// AttributeType - forces type of attribute value, e.g. for numbers sent as strings
+type AttributeType string
+
+const (
+	AttributeString AttributeType = "string"
+	AttributeInt    AttributeType = "int"
+	AttributeDouble AttributeType = "double"
+	AttributeBool   AttributeType = "bool"
+)
+
+func (t AttributeType) IsValid() bool {
+	switch t {
+	case "", AttributeString, AttributeInt, AttributeDouble, AttributeBool:
+		return true
+	}
+	return false
+}
+
+// valueHolder - values wrapping the native one, like CEL's ref.Val or jsonquery node
+type valueHolder interface {
+	Value() interface{}
+}
+
+// evaluateAttribute - returns value of the attribute converted to its type if set
+func (g *Scraper) evaluateAttribute(attr *Attribute, doc *jsonquery.Node, scContext *scraperContext) (any, error) {
+	var value any
+	if attr.Value != "" {
+		value = attr.Value
+	} else {
+		var err error
+		value, err = g.evaluateRawValueFrom(doc, attr.ValueFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", attr.ValueFrom, err)
+		}
+	}
+	return convertAttribute(value, attr.Type)
+}
+
+// convertAttribute - converts value to the attribute type, empty type keeps the value as is
+func convertAttribute(value any, attrType AttributeType) (any, error) {
+	if holder, ok := value.(valueHolder); ok {
+		value = holder.Value()
+	}
+	if attrType == "" || value == nil {
+		return value, nil
+	}
+
+	str := strings.TrimSpace(fmt.Sprintf("%v", value))
+	switch attrType {
+	case AttributeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		return str, nil
+	case AttributeInt:
+		switch v := value.(type) {
+		case float64:
+			return int64(v), nil
+		case float32:
+			return int64(v), nil
+		}
+		if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %s is not int", str)
+		}
+		return int64(f), nil
+	case AttributeDouble:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("value %s is not double", str)
+		}
+		return f, nil
+	case AttributeBool:
+		switch strings.ToLower(str) {
+		case "yes", "on":
+			return true, nil
+		case "no", "off":
+			return false, nil
+		}
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return nil, fmt.Errorf("value %s is not bool", str)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown attribute type %s", attrType)
+}
+
+// setAttributeValue - sets value keeping its type, slices and maps are converted recursively
+func setAttributeValue(dest pcommon.Value, value any) {
+	switch v := value.(type) {
+	case nil:
+	case string:
+		dest.SetStr(v)
+	case bool:
+		dest.SetBool(v)
+	case int:
+		dest.SetInt(int64(v))
+	case int8:
+		dest.SetInt(int64(v))
+	case int16:
+		dest.SetInt(int64(v))
+	case int32:
+		dest.SetInt(int64(v))
+	case int64:
+		dest.SetInt(v)
+	case uint:
+		dest.SetInt(int64(v))
+	case uint8:
+		dest.SetInt(int64(v))
+	case uint16:
+		dest.SetInt(int64(v))
+	case uint32:
+		dest.SetInt(int64(v))
+	case uint64:
+		dest.SetInt(int64(v))
+	case float32:
+		dest.SetDouble(float64(v))
+	case float64:
+		dest.SetDouble(v)
+	case []byte:
+		dest.SetEmptyBytes().FromRaw(v)
+	case []string:
+		slice := dest.SetEmptySlice()
+		for _, elem := range v {
+			slice.AppendEmpty().SetStr(elem)
+		}
+	case []any:
+		slice := dest.SetEmptySlice()
+		for _, elem := range v {
+			setAttributeValue(slice.AppendEmpty(), elem)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // stable output for the same input
+		m := dest.SetEmptyMap()
+		for _, key := range keys {
+			setAttributeValue(m.PutEmpty(key), v[key])
+		}
+	case valueHolder:
+		setAttributeValue(dest, v.Value())
+	default:
+		// other slices and maps, e.g. CEL lists of ref.Val
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array:
+			slice := dest.SetEmptySlice()
+			for i := 0; i < rv.Len(); i++ {
+				setAttributeValue(slice.AppendEmpty(), rv.Index(i).Interface())
+			}
+		case reflect.Map:
+			entries := map[string]any{}
+			iter := rv.MapRange()
+			for iter.Next() {
+				key := iter.Key().Interface()
+				if holder, ok := key.(valueHolder); ok {
+					key = holder.Value()
+				}
+				entries[fmt.Sprintf("%v", key)] = iter.Value().Interface()
+			}
+			setAttributeValue(dest, entries)
+		default:
+			dest.SetStr(fmt.Sprintf("%v", value))
+		}
+	}
+}
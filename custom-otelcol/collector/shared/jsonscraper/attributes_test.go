@@ -0,0 +1,75 @@
+package jsonscraper
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// celVal - stands for CEL's ref.Val wrapping native values
+type celVal struct {
+	value any
+}
+
+func (v celVal) Value() interface{} {
+	return v.value
+}
+
+func TestConvertAttribute(t *testing.T) {
+	tests := []struct {
+		value    any
+		attrType AttributeType
+		expected any
+	}{
+		{"42", "", "42"},
+		{"42", AttributeInt, int64(42)},
+		{"42.7", AttributeInt, int64(42)},
+		{42.0, AttributeInt, int64(42)},
+		{"0.25", AttributeDouble, 0.25},
+		{"yes", AttributeBool, true},
+		{"false", AttributeBool, false},
+		{42.5, AttributeString, "42.5"},
+		{celVal{"7"}, AttributeInt, int64(7)},
+	}
+
+	for _, test := range tests {
+		actual, err := convertAttribute(test.value, test.attrType)
+		if err != nil {
+			t.Fatalf("%v as %s - %v", test.value, test.attrType, err)
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v as %s: expected: %T %v != actual: %T %v", test.value, test.attrType, test.expected, test.expected, actual, actual)
+		}
+	}
+
+	if _, err := convertAttribute("n/a", AttributeInt); err == nil {
+		t.Errorf("expected error converting n/a to int")
+	}
+}
+
+func TestSetAttributeValue(t *testing.T) {
+	attrs := pcommon.NewMap()
+	setAttributeValue(attrs.PutEmpty("int"), int64(3))
+	setAttributeValue(attrs.PutEmpty("double"), 1.5)
+	setAttributeValue(attrs.PutEmpty("bool"), true)
+	setAttributeValue(attrs.PutEmpty("json"), map[string]any{
+		"name":  "leaf1",
+		"ports": []any{1.0, "eth1/2"},
+	})
+	setAttributeValue(attrs.PutEmpty("cel"), []celVal{{int64(1)}, {"b"}})
+
+	expected := map[string]any{
+		"int":    int64(3),
+		"double": 1.5,
+		"bool":   true,
+		"json": map[string]any{
+			"name":  "leaf1",
+			"ports": []any{1.0, "eth1/2"},
+		},
+		"cel": []any{int64(1), "b"},
+	}
+	if !reflect.DeepEqual(attrs.AsRaw(), expected) {
+		t.Fatalf("expected: %v != actual: %v", expected, attrs.AsRaw())
+	}
+}
@@ -0,0 +1,157 @@
+package jsonscraper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// emitBatch - metrics and logs emitted during one scrape, grouped by resource and scope.
+// The batch goes to the consumer at the end of the scrape, or whenever it reaches flushSize.
+type emitBatch struct {
+	emitter   *Emitter
+	flushSize int // data points and log records, 0 means flush only at the end of scrape
+
+	mutex           sync.Mutex
+	size            int
+	metrics         pmetric.Metrics
+	resourceMetrics map[string]pmetric.ResourceMetrics
+	scopeMetrics    map[string]pmetric.ScopeMetrics
+	metricsByName   map[string]pmetric.Metric
+	logs            plog.Logs
+	resourceLogs    map[string]plog.ResourceLogs
+	scopeLogs       map[string]plog.ScopeLogs
+	errs            []error
+}
+
+func (e *Emitter) newBatch(flushSize int) *emitBatch {
+	batch := &emitBatch{
+		emitter:   e,
+		flushSize: flushSize,
+	}
+	batch.reset()
+	return batch
+}
+
+func (b *emitBatch) reset() {
+	b.size = 0
+	b.metrics = pmetric.NewMetrics()
+	b.resourceMetrics = map[string]pmetric.ResourceMetrics{}
+	b.scopeMetrics = map[string]pmetric.ScopeMetrics{}
+	b.metricsByName = map[string]pmetric.Metric{}
+	b.logs = plog.NewLogs()
+	b.resourceLogs = map[string]plog.ResourceLogs{}
+	b.scopeLogs = map[string]plog.ScopeLogs{}
+}
+
+// metric - returns metric of the given name, type and resource & scope, created is true
+// when the metric is new in the batch and needs its metadata set
+func (b *emitBatch) metric(rsrcAttrs map[string]any, scope *Scope, name string, metricType MetricType) (metric pmetric.Metric, created bool) {
+	rsrcKey := attributesKey(rsrcAttrs)
+	resourceMetrics, ok := b.resourceMetrics[rsrcKey]
+	if !ok {
+		resourceMetrics = b.metrics.ResourceMetrics().AppendEmpty()
+		resAttrs := resourceMetrics.Resource().Attributes()
+		b.emitter.upsertAttributes(&resAttrs, rsrcAttrs)
+		b.resourceMetrics[rsrcKey] = resourceMetrics
+	}
+
+	scopeKey := rsrcKey + "\x00" + scope.Name + "\x00" + scope.Version
+	scopeMetrics, ok := b.scopeMetrics[scopeKey]
+	if !ok {
+		scopeMetrics = resourceMetrics.ScopeMetrics().AppendEmpty()
+		scopeMetrics.Scope().SetName(scope.Name)
+		scopeMetrics.Scope().SetVersion(scope.Version)
+		b.scopeMetrics[scopeKey] = scopeMetrics
+	}
+
+	metricKey := scopeKey + "\x00" + name + "\x00" + string(metricType)
+	metric, ok = b.metricsByName[metricKey]
+	if !ok {
+		metric = scopeMetrics.Metrics().AppendEmpty()
+		b.metricsByName[metricKey] = metric
+		created = true
+	}
+	return metric, created
+}
+
+// logRecord - returns new log record under the given resource and scope
+func (b *emitBatch) logRecord(rsrcAttrs map[string]any, scope *Scope) plog.LogRecord {
+	rsrcKey := attributesKey(rsrcAttrs)
+	resourceLogs, ok := b.resourceLogs[rsrcKey]
+	if !ok {
+		resourceLogs = b.logs.ResourceLogs().AppendEmpty()
+		resAttrs := resourceLogs.Resource().Attributes()
+		b.emitter.upsertAttributes(&resAttrs, rsrcAttrs)
+		b.resourceLogs[rsrcKey] = resourceLogs
+	}
+
+	scopeKey := rsrcKey + "\x00" + scope.Name + "\x00" + scope.Version
+	scopeLogs, ok := b.scopeLogs[scopeKey]
+	if !ok {
+		scopeLogs = resourceLogs.ScopeLogs().AppendEmpty()
+		scopeLogs.Scope().SetName(scope.Name)
+		scopeLogs.Scope().SetVersion(scope.Version)
+		b.scopeLogs[scopeKey] = scopeLogs
+	}
+
+	return scopeLogs.LogRecords().AppendEmpty()
+}
+
+// added - counts one data point or log record, must be called with mutex locked
+func (b *emitBatch) added() {
+	b.size++
+	if b.flushSize > 0 && b.size >= b.flushSize {
+		b.flushLocked()
+	}
+}
+
+func (b *emitBatch) flushLocked() {
+	if b.size == 0 {
+		return
+	}
+
+	if b.metrics.DataPointCount() > 0 {
+		if err := b.emitter.ConsumeMetrics(b.metrics); err != nil {
+			b.errs = append(b.errs, fmt.Errorf("cannot consume %d data points - %v", b.metrics.DataPointCount(), err))
+		}
+	}
+	if b.logs.LogRecordCount() > 0 {
+		b.emitter.logger.Sugar().Debugf("Flushing logs... %d records", b.logs.LogRecordCount())
+		if err := b.emitter.ConsumeLogs(b.logs); err != nil {
+			b.errs = append(b.errs, fmt.Errorf("cannot consume %d log records - %v", b.logs.LogRecordCount(), err))
+		}
+	}
+	b.reset()
+}
+
+// finish - flushes the rest of the batch, returns errors of all flushes of the batch
+func (b *emitBatch) finish() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.flushLocked()
+	err := errors.Join(b.errs...)
+	b.errs = nil
+	return err
+}
+
+// attributesKey - stable string identifying the set of attributes
+func attributesKey(attrs map[string]any) string {
+	names := make([]string, 0, len(attrs))
+	for n := range attrs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	key := strings.Builder{}
+	for _, n := range names {
+		fmt.Fprintf(&key, "%s=%T:%v\x00", n, attrs[n], attrs[n])
+	}
+	return key.String()
+}
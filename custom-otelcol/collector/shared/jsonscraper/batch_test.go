@@ -0,0 +1,93 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// batchSink - keeps every bundle the consumer gets
+type batchSink struct {
+	bundles []pmetric.Metrics
+	err     error
+}
+
+func (s *batchSink) consume(_ context.Context, metrics pmetric.Metrics) error {
+	s.bundles = append(s.bundles, metrics)
+	return s.err
+}
+
+func runBatchTestScrape(t *testing.T, sink *batchSink, flushSize int) error {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(parallelTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	config.FlushSize = flushSize
+
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, parallelTestClient(), emitter, config, 60, nil)
+
+	return scraper.scrape(context.Background(), config.Queries, 60)
+}
+
+func TestBatchGroupsByResourceAndMetric(t *testing.T) {
+	sink := &batchSink{}
+	err := runBatchTestScrape(t, sink, 0)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+	if len(sink.bundles) != 1 {
+		t.Fatalf("expected: 1 bundle != actual: %d", len(sink.bundles))
+	}
+
+	metrics := sink.bundles[0]
+	if metrics.DataPointCount() != 9 {
+		t.Fatalf("expected: 9 data points != actual: %d", metrics.DataPointCount())
+	}
+	// 4 nodes + fabric
+	if metrics.ResourceMetrics().Len() != 5 {
+		t.Fatalf("expected: 5 resources != actual: %d", metrics.ResourceMetrics().Len())
+	}
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		if rm.ScopeMetrics().Len() != 1 || rm.ScopeMetrics().At(0).Metrics().Len() != 1 {
+			t.Fatalf("expected one scope with one metric per resource, got %v", rm.Resource().Attributes().AsRaw())
+		}
+		metric := rm.ScopeMetrics().At(0).Metrics().At(0)
+		if metric.Name() == "psu.power" && metric.Gauge().DataPoints().Len() != 2 {
+			t.Fatalf("expected: 2 psu data points != actual: %d", metric.Gauge().DataPoints().Len())
+		}
+	}
+}
+
+func TestBatchFlushSizeAndConsumerError(t *testing.T) {
+	sink := &batchSink{}
+	err := runBatchTestScrape(t, sink, 4)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+	sizes := []string{}
+	for _, bundle := range sink.bundles {
+		sizes = append(sizes, fmt.Sprint(bundle.DataPointCount()))
+	}
+	if strings.Join(sizes, ",") != "4,4,1" {
+		t.Fatalf("expected: 4,4,1 data points != actual: %v", sizes)
+	}
+
+	sink = &batchSink{err: fmt.Errorf("queue full")}
+	err = runBatchTestScrape(t, sink, 4)
+	if err == nil || !strings.Contains(err.Error(), "queue full") {
+		t.Fatalf("expected consumer error from scrape, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// ResponseCache - parsed responses of the service keyed on method, URL, body and format.
+// Concurrent requests for the same key wait for the first one, so the service is called once.
+type ResponseCache struct {
+	mutex   sync.Mutex
+	entries map[string]*cacheEntry
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+type cacheEntry struct {
+	ready   chan struct{} // closed when doc and err are set
+	doc     *jsonquery.Node
+	err     error
+	expires time.Time
+}
+
+var sharedCaches = struct {
+	sync.Mutex
+	caches map[string]*ResponseCache
+}{
+	caches: map[string]*ResponseCache{},
+}
+
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+// SharedResponseCache - returns process wide cache of the given name, receivers scraping
+// the same service use the same name to share responses
+func SharedResponseCache(name string) *ResponseCache {
+	sharedCaches.Lock()
+	defer sharedCaches.Unlock()
+	cache, ok := sharedCaches.caches[name]
+	if !ok {
+		cache = NewResponseCache()
+		sharedCaches.caches[name] = cache
+	}
+	return cache
+}
+
+// Hits - number of responses served from the cache
+func (c *ResponseCache) Hits() int64 {
+	return c.hits.Load()
+}
+
+// Misses - number of responses requested from the service
+func (c *ResponseCache) Misses() int64 {
+	return c.misses.Load()
+}
+
+// get - returns cached document or calls fetch, failed fetches are not cached
+func (c *ResponseCache) get(ctx context.Context, key string, ttl time.Duration, fetch func() (*jsonquery.Node, error)) (*jsonquery.Node, error) {
+	now := time.Now()
+
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	if ok && (!isReady(entry) || now.Before(entry.expires)) {
+		c.mutex.Unlock()
+		c.hits.Add(1)
+		select {
+		case <-entry.ready:
+			return entry.doc, entry.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c.purgeLocked(now)
+	entry = &cacheEntry{ready: make(chan struct{})}
+	c.entries[key] = entry
+	c.mutex.Unlock()
+	c.misses.Add(1)
+
+	entry.doc, entry.err = fetch()
+	entry.expires = time.Now().Add(ttl)
+	close(entry.ready)
+
+	if entry.err != nil {
+		c.mutex.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mutex.Unlock()
+	}
+	return entry.doc, entry.err
+}
+
+func (c *ResponseCache) purgeLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if isReady(entry) && !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func isReady(entry *cacheEntry) bool {
+	select {
+	case <-entry.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// cacheKey - rules paginating the same URL differently get differently merged documents,
+// so all pagination settings are part of the key
+func cacheKey(rule *Rule, method string, url string, payload *string) string {
+	body := ""
+	if payload != nil {
+		body = *payload
+	}
+	paginate := ""
+	if rule.Paginate != nil {
+		paginate = fmt.Sprintf("%+v", *rule.Paginate)
+	}
+	return fmt.Sprintf("%s %s %s %q %q", method, url, rule.Format, paginate, body)
+}
+
+// SetResponseCache - replaces scraper's own cache, e.g. by a shared one
+func (g *Scraper) SetResponseCache(cache *ResponseCache) {
+	g.cache = cache
+}
+
+// ResponseCache - cache used by the scraper
+func (g *Scraper) ResponseCache() *ResponseCache {
+	return g.cache
+}
+
+// getCachedDataFromService - reads the rule's query through the cache when the query has cacheTTL
+func (g *Scraper) getCachedDataFromService(scContext *scraperContext, rule *Rule, method string, url string, payload *string) (*jsonquery.Node, error) {
+	fetch := func() (doc *jsonquery.Node, err error) {
+		start := time.Now()
+		defer func() {
+			g.telemetry.recordRequest(scContext.runCtx, scContext.query, rule.Query, start, err)
+		}()
+		if rule.Paginate == nil {
+			return g.getDataFromService(scContext.runCtx, rule.Format, method, url, payload)
+		}
+		return g.getPagedDataFromService(scContext.runCtx, rule.Paginate, rule.Format, method, url, payload)
+	}
+
+	if scContext.query == nil || scContext.query.CacheTTL <= 0 || g.cache == nil {
+		return fetch()
+	}
+	ttl := time.Duration(scContext.query.CacheTTL) * time.Second
+	fetched := false
+	doc, err := g.cache.get(scContext.runCtx, cacheKey(rule, method, url, payload), ttl, func() (*jsonquery.Node, error) {
+		fetched = true
+		return fetch()
+	})
+	g.telemetry.recordCacheLookup(scContext.runCtx, scContext.query, rule.Query, !fetched)
+	return doc, err
+}
@@ -0,0 +1,153 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/antchfx/jsonquery"
+	"go.uber.org/zap"
+)
+
+const cacheTestQueries = `
+queries:
+- name: Health
+  cacheTTL: 60
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /api/class/fabricNode.json
+- name: Power
+  cacheTTL: 60
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /api/class/fabricNode.json
+- name: Uncached
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /api/class/fabricNode.json
+`
+
+func TestResponseCacheSharedByQueries(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(cacheTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/api/class/fabricNode.json": `{"imdata":[]}`,
+		},
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, nil, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	cache := SharedResponseCache("test-apic")
+	scraper.SetResponseCache(cache)
+	meter := &recordingMeter{values: map[string]float64{}}
+	if err = scraper.SetMeterProvider(&recordingMeterProvider{meter: meter}); err != nil {
+		t.Fatalf("Cannot set meter provider - %v", err)
+	}
+
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+	if len(client.requests) != 2 {
+		t.Fatalf("expected: 2 requests != actual: %v", client.requests)
+	}
+	if cache.Hits() != 1 || cache.Misses() != 1 {
+		t.Fatalf("expected: 1 hit, 1 miss != actual: %d, %d", cache.Hits(), cache.Misses())
+	}
+	values := meter.collect()
+	expected := map[string]float64{
+		"jsonscraper_cache_misses{query=Health,receiver=test,url=/api/class/fabricNode.json}": 1,
+		"jsonscraper_cache_hits{query=Power,receiver=test,url=/api/class/fabricNode.json}":    1,
+	}
+	for key, value := range expected {
+		if actual, ok := values[key]; !ok || actual != value {
+			t.Errorf("%s expected: %v != actual: %v\nall: %v", key, value, actual, values)
+		}
+	}
+	if _, ok := values["jsonscraper_cache_hits{query=Uncached,receiver=test,url=/api/class/fabricNode.json}"]; ok {
+		t.Errorf("expected no cache lookup of query without cacheTTL")
+	}
+	if SharedResponseCache("test-apic") != scraper.ResponseCache() {
+		t.Fatalf("expected the same shared cache")
+	}
+}
+
+func TestResponseCacheConcurrentAndErrors(t *testing.T) {
+	cache := NewResponseCache()
+	calls := atomic.Int64{}
+	release := make(chan struct{})
+	fetch := func() (*jsonquery.Node, error) {
+		calls.Add(1)
+		<-release
+		return &jsonquery.Node{}, nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.get(context.Background(), "GET /a", time.Minute, fetch); err != nil {
+				t.Errorf("Unexpected error - %v", err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 || cache.Hits() != 4 || cache.Misses() != 1 {
+		t.Fatalf("expected: 1 call, 4 hits, 1 miss != actual: %d, %d, %d", calls.Load(), cache.Hits(), cache.Misses())
+	}
+
+	failing := func() (*jsonquery.Node, error) {
+		calls.Add(1)
+		return nil, fmt.Errorf("service unavailable")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := cache.get(context.Background(), "GET /b", time.Minute, failing); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	for i := 0; i < 2; i++ {
+		cache.get(context.Background(), "GET /c", 0, fetch)
+	}
+	if calls.Load() != 5 {
+		t.Fatalf("failed and expired responses should not be cached, calls %d", calls.Load())
+	}
+}
+
+func TestCacheKeyPaginate(t *testing.T) {
+	plain := &Rule{}
+	paged := &Rule{Paginate: &Paginate{Strategy: PaginateAci, PageSize: 50}}
+	otherSize := &Rule{Paginate: &Paginate{Strategy: PaginateAci, PageSize: 100}}
+	otherPages := &Rule{Paginate: &Paginate{Strategy: PaginateAci, PageSize: 50, MaxPages: 2}}
+	otherItems := &Rule{Paginate: &Paginate{Strategy: PaginateAci, PageSize: 50, ItemsPath: "imdata"}}
+
+	keys := map[string]bool{}
+	for _, rule := range []*Rule{plain, paged, otherSize, otherPages, otherItems} {
+		keys[cacheKey(rule, "GET", "/api/class/faultInst.json", nil)] = true
+	}
+	if len(keys) != 5 {
+		t.Fatalf("expected: 5 keys != actual: %v", keys)
+	}
+	same := &Rule{Paginate: &Paginate{Strategy: PaginateAci, PageSize: 50}}
+	if cacheKey(paged, "GET", "/api/class/faultInst.json", nil) != cacheKey(same, "GET", "/api/class/faultInst.json", nil) {
+		t.Fatalf("expected rules with the same pagination to share the key")
+	}
+}
@@ -0,0 +1,80 @@
+package jsonscraper
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+)
+
+const childrenTestQueries = `
+queries:
+- name: Nodes
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /nodes
+    select: imdata//fabricNode
+    forEach:
+      when: =jqs("attributes/role") == "leaf"
+      resourceAttributes:
+      - name: aci.node.name
+        valueFrom: attributes/name
+      children:
+      - select: psus/*
+        forEach:
+          emitMetric:
+          - name: psu.power
+            type: gauge
+            valueFrom: power
+      - select: fans/*
+        forEach:
+          when: jqs("speed") != "0" # leading = is optional
+          emitMetric:
+          - name: fan.speed
+            type: gauge
+            valueFrom: speed
+`
+
+func TestChildrenAndWhen(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(childrenTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/nodes": `{"imdata":[
+				{"fabricNode":{"attributes":{"name":"leaf1","role":"leaf"},"psus":[{"power":"100"}],"fans":[{"speed":"3000"},{"speed":"0"}]}},
+				{"fabricNode":{"attributes":{"name":"spine1","role":"spine"},"psus":[{"power":"200"}],"fans":[{"speed":"4000"}]}}
+			]}`,
+		},
+	}
+
+	sink := &metricSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	if len(client.requests) != 1 {
+		t.Fatalf("expected: 1 request != actual: %v", client.requests)
+	}
+	sort.Strings(sink.points)
+	expected := "fan.speed map[aci.node.name:leaf1] map[] 3000|psu.power map[aci.node.name:leaf1] map[] 100"
+	if strings.Join(sink.points, "|") != expected {
+		t.Fatalf("expected: %s != actual: %s", expected, strings.Join(sink.points, "|"))
+	}
+}
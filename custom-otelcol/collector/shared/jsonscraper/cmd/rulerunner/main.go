@@ -0,0 +1,346 @@
+// rulerunner - runs query files offline against recorded responses and prints
+// emitted metrics, logs and DB records. No collector and no network needed.
+//
+//	go run ./cmd/rulerunner -queries ../../../conf/aci/aci-node-pwr.yaml -responses ./recorded -trace
+//
+// The responses directory contains fixtures recorded by the receivers' recordDir, one
+// NNNN-method-path.json file per request:
+//
+//	{"method": "GET", "url": "/api/class/fabricNode.json", "response": {"imdata": []}, "time": "2024-05-14T08:13:05Z"}
+//
+// now() in expressions returns the time of the first recorded response.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/antchfx/jsonquery"
+	contextdb "github.com/chrlic/otelcol-cust/collector/shared/contextdb"
+	"github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+type options struct {
+	queries   stringList
+	schemas   stringList
+	responses string
+	output    string
+	trace     bool
+	debug     bool
+	interval  int
+}
+
+func main() {
+	opts := options{}
+	flag.Var(&opts.queries, "queries", "query file, can be repeated")
+	flag.Var(&opts.schemas, "schema", "contextdb table schema file, can be repeated")
+	flag.StringVar(&opts.responses, "responses", ".", "directory with fixtures recorded by recordDir")
+	flag.StringVar(&opts.output, "output", "table", "output format - table or json")
+	flag.BoolVar(&opts.trace, "trace", false, "print result of each expression evaluation to stderr")
+	flag.BoolVar(&opts.debug, "debug", false, "print scraper debug log to stderr")
+	flag.IntVar(&opts.interval, "interval", 60, "scrape interval in seconds used for emitted data")
+	flag.Parse()
+
+	if err := run(opts, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "rulerunner: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options, stdout io.Writer, stderr io.Writer) error {
+	if len(opts.queries) == 0 {
+		return fmt.Errorf("at least one -queries file required")
+	}
+	if opts.output != "table" && opts.output != "json" {
+		return fmt.Errorf("unknown output %s, use table or json", opts.output)
+	}
+
+	logger := zap.NewNop()
+	if opts.debug {
+		loggerConfig := zap.NewDevelopmentConfig()
+		loggerConfig.OutputPaths = []string{"stderr"}
+		var err error
+		if logger, err = loggerConfig.Build(); err != nil {
+			return err
+		}
+	}
+
+	config := jsonscraper.NewScraperConfig()
+	for _, file := range opts.queries {
+		queryConfig, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("cannot read query file %s - %v", file, err)
+		}
+		if err = config.AddQueryRulesFrom(file, queryConfig); err != nil {
+			return err
+		}
+	}
+
+	db, tables, err := initContextDb(opts.schemas, logger)
+	if err != nil {
+		return err
+	}
+	if len(opts.schemas) > 0 {
+		if err = config.ValidateTables(tables); err != nil {
+			return err
+		}
+	}
+
+	metrics := pmetric.NewMetrics()
+	logs := plog.NewLogs()
+	mutex := sync.Mutex{}
+	metricConsumer, _ := consumer.NewMetrics(func(_ context.Context, md pmetric.Metrics) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		md.ResourceMetrics().MoveAndAppendTo(metrics.ResourceMetrics())
+		return nil
+	})
+	logConsumer, _ := consumer.NewLogs(func(_ context.Context, ld plog.Logs) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		ld.ResourceLogs().MoveAndAppendTo(logs.ResourceLogs())
+		return nil
+	})
+
+	// emitter takes either metrics or logs, so the queries run once for each signal
+	// the same way the collector runs them in metrics and logs receivers
+	passes := []struct {
+		signal  string
+		emitter jsonscraper.Emitter
+	}{
+		{"metrics", jsonscraper.NewEmitter(context.Background(), logger, metricConsumer, nil)},
+		{"logs", jsonscraper.NewEmitter(context.Background(), logger, nil, logConsumer)},
+	}
+	missed := []string{}
+	for _, pass := range passes {
+		signal := pass.signal
+		// each pass replays the recording like each of the collector's receivers scrapes on its own
+		client, err := jsonscraper.NewReplayClient(opts.responses)
+		if err != nil {
+			return err
+		}
+		scraper := jsonscraper.NewScraper("rulerunner", logger, client, pass.emitter, config, opts.interval, db)
+		if recordedAt := client.RecordedAt(); !recordedAt.IsZero() {
+			scraper.SetClock(func() time.Time { return recordedAt })
+		}
+		if opts.trace {
+			scraper.SetEvaluationTrace(func(expr string, value any, err error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if err != nil {
+					fmt.Fprintf(stderr, "TRACE %s: %s => error %v\n", signal, strings.TrimSpace(expr), err)
+				} else {
+					fmt.Fprintf(stderr, "TRACE %s: %s => %v (%T)\n", signal, strings.TrimSpace(expr), value, value)
+				}
+			})
+		}
+		if err = scraper.ScrapeOnce(context.Background()); err != nil {
+			return fmt.Errorf("scrape of %s failed - %v", signal, err)
+		}
+		missed = append(missed, client.Missed()...)
+	}
+	sort.Strings(missed)
+	for i, request := range missed {
+		if i > 0 && missed[i-1] == request {
+			continue
+		}
+		fmt.Fprintf(stderr, "MISSING response for %s\n", request)
+	}
+
+	records := dbRecords(db, tables)
+	if opts.output == "json" {
+		return printJSON(stdout, metrics, logs, records)
+	}
+	printTable(stdout, metrics, logs, records)
+	return nil
+}
+
+func initContextDb(schemaFiles []string, logger *zap.Logger) (*contextdb.ContextDb, []string, error) {
+	if len(schemaFiles) == 0 {
+		return nil, nil, nil
+	}
+	schemas := contextdb.ContextDbSchema{}
+	for _, file := range schemaFiles {
+		schemaConfig, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read db schema yaml %s - %v", file, err)
+		}
+		schema, err := contextdb.ParseDbJsonSchema(schemaConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot parse db schema file %s - %v", file, err)
+		}
+		schemas = contextdb.AppendDbJsonSchema(schemas, schema)
+	}
+	dbSchema, err := contextdb.GetDbSchema(schemas)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot convert schema to memdb schema - %v", err)
+	}
+	db := &contextdb.ContextDb{}
+	if err = db.Init(dbSchema, logger); err != nil {
+		return nil, nil, fmt.Errorf("cannot init DB - %v", err)
+	}
+
+	tables := []string{}
+	for _, table := range schemas {
+		tables = append(tables, table.Name)
+	}
+	return db, tables, nil
+}
+
+// dbRecords - returns data of all records by table name
+func dbRecords(db *contextdb.ContextDb, tables []string) map[string][]any {
+	records := map[string][]any{}
+	for _, table := range tables {
+		records[table] = []any{}
+		txn := db.Db.Txn(false)
+		iterator, err := txn.Get(table, "id")
+		if err != nil {
+			continue
+		}
+		for obj := iterator.Next(); obj != nil; obj = iterator.Next() {
+			if record, ok := obj.(contextdb.ContextRecord); ok && record.Data != nil {
+				records[table] = append(records[table], recordValue(record.Data))
+			}
+		}
+		txn.Abort()
+	}
+	return records
+}
+
+// recordValue - fields of the record, records are stored as documents with one element per field
+func recordValue(data contextdb.ContextData) map[string]any {
+	fields := map[string]any{}
+	for field := (*jsonquery.Node)(data).FirstChild; field != nil; field = field.NextSibling {
+		fields[field.Data] = field.Value()
+	}
+	return fields
+}
+
+func printJSON(stdout io.Writer, metrics pmetric.Metrics, logs plog.Logs, records map[string][]any) error {
+	metricsJSON, err := (&pmetric.JSONMarshaler{}).MarshalMetrics(metrics)
+	if err != nil {
+		return err
+	}
+	logsJSON, err := (&plog.JSONMarshaler{}).MarshalLogs(logs)
+	if err != nil {
+		return err
+	}
+	output := map[string]any{
+		"metrics":   json.RawMessage(metricsJSON),
+		"logs":      json.RawMessage(logsJSON),
+		"dbRecords": records,
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+func printTable(stdout io.Writer, metrics pmetric.Metrics, logs plog.Logs, records map[string][]any) {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "METRIC\tTYPE\tVALUE\tATTRIBUTES\tRESOURCE")
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		resource := formatAttributes(rm.Resource().Attributes())
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			ms := rm.ScopeMetrics().At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				for _, row := range metricRows(ms.At(k)) {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ms.At(k).Name(), ms.At(k).Type(), row[0], row[1], resource)
+				}
+			}
+		}
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "TIMESTAMP\tSEVERITY\tBODY\tATTRIBUTES\tRESOURCE")
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		rl := logs.ResourceLogs().At(i)
+		resource := formatAttributes(rl.Resource().Attributes())
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			lrs := rl.ScopeLogs().At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				lr := lrs.At(k)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", lr.Timestamp().AsTime().Format(time.RFC3339), lr.SeverityNumber(), lr.Body().AsString(), formatAttributes(lr.Attributes()), resource)
+			}
+		}
+	}
+	fmt.Fprintln(w)
+
+	tables := []string{}
+	for table := range records {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	fmt.Fprintln(w, "TABLE\tRECORD")
+	for _, table := range tables {
+		for _, record := range records[table] {
+			recordJSON, _ := json.Marshal(record)
+			fmt.Fprintf(w, "%s\t%s\n", table, recordJSON)
+		}
+	}
+	w.Flush()
+}
+
+// metricRows - value and attributes of each data point
+func metricRows(metric pmetric.Metric) [][2]string {
+	rows := [][2]string{}
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge, pmetric.MetricTypeSum:
+		dps := metric.Gauge().DataPoints()
+		if metric.Type() == pmetric.MetricTypeSum {
+			dps = metric.Sum().DataPoints()
+		}
+		for i := 0; i < dps.Len(); i++ {
+			rows = append(rows, [2]string{fmt.Sprintf("%v", dps.At(i).DoubleValue()), formatAttributes(dps.At(i).Attributes())})
+		}
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			value := fmt.Sprintf("count=%d sum=%v buckets=%v", dps.At(i).Count(), dps.At(i).Sum(), dps.At(i).BucketCounts().AsRaw())
+			rows = append(rows, [2]string{value, formatAttributes(dps.At(i).Attributes())})
+		}
+	case pmetric.MetricTypeSummary:
+		dps := metric.Summary().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			value := fmt.Sprintf("count=%d sum=%v", dps.At(i).Count(), dps.At(i).Sum())
+			rows = append(rows, [2]string{value, formatAttributes(dps.At(i).Attributes())})
+		}
+	}
+	return rows
+}
+
+func formatAttributes(attributes pcommon.Map) string {
+	pairs := []string{}
+	attributes.Range(func(k string, v pcommon.Value) bool {
+		pairs = append(pairs, k+"="+v.AsString())
+		return true
+	})
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunTable(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	opts := options{
+		queries:   stringList{"testdata/queries.yaml"},
+		schemas:   stringList{"testdata/schema.yaml"},
+		responses: "testdata",
+		output:    "table",
+		trace:     true,
+		interval:  60,
+	}
+	if err := run(opts, stdout, stderr); err != nil {
+		t.Fatalf("Run failed - %v", err)
+	}
+
+	for _, expected := range []string{"aci.node.health  Gauge  95     aci.node.name=leaf1", "aci.node.name=leaf2", `nodes  {"name":"leaf2"}`} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Fatalf("expected %q in output:\n%s", expected, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), `TRACE metrics: int(jqs("imdata//healthInst/attributes/cur")) => 80 (int64)`) {
+		t.Fatalf("expected evaluation trace, got:\n%s", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "MISSING") {
+		t.Fatalf("unexpected missing responses:\n%s", stderr.String())
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	opts := options{
+		queries:   stringList{"testdata/queries.yaml"},
+		responses: "testdata",
+		output:    "json",
+		interval:  60,
+	}
+	if err := run(opts, stdout, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Run failed - %v", err)
+	}
+
+	output := struct {
+		Metrics struct {
+			ResourceMetrics []any `json:"resourceMetrics"`
+		} `json:"metrics"`
+	}{}
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		t.Fatalf("Output is not JSON - %v", err)
+	}
+	if len(output.Metrics.ResourceMetrics) == 0 {
+		t.Fatalf("expected OTLP metrics in output:\n%s", stdout.String())
+	}
+}
@@ -3,18 +3,33 @@ package jsonscraper
 import (
 	"fmt"
 
+	"go.opentelemetry.io/collector/pdata/pmetric"
 	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Queries []*Query `yaml:"queries"`
+	Queries        []*Query     `yaml:"queries"`
+	MaxConcurrency int          `yaml:"-"` // queries scraped in parallel, set by the receiver
+	OverlapPolicy  ScrapePolicy `yaml:"-"` // skip, queue, or cancelPrevious, set by the receiver
+	ScrapeTimeout  int          `yaml:"-"` // seconds, 0 means no timeout, set by the receiver
+	Jitter         int          `yaml:"-"` // seconds, max random delay of scheduled queries, set by the receiver
+	FlushSize      int          `yaml:"-"` // data points or log records sent at once, 0 means whole scrape, set by the receiver
+	ErrorLogs      bool         `yaml:"-"` // log receivers emit a log record for each collection error, set by the receiver
+	tableRefs      []tableRef   // tables of emitDbRecord rules, see ValidateTables
+	includes       []string     // files included by the query files
 }
 
 type Query struct {
-	Name     string    `yaml:"name"`
-	Rules    Rule      `yaml:"rules"`
-	Resource *Resource `yaml:"resource"`
-	Scope    *Scope    `yaml:"scope"`
+	Name           string    `yaml:"name"`
+	Rules          Rule      `yaml:"rules"`
+	Resource       *Resource `yaml:"resource"`
+	Scope          *Scope    `yaml:"scope"`
+	MaxConcurrency int       `yaml:"maxConcurrency"` // ForEach branches running in parallel within the query
+	Interval       int       `yaml:"interval"`       // seconds, overrides the receiver's interval
+	Cron           string    `yaml:"cron"`           // 5 field cron expression, alternative to interval
+	Jitter         int       `yaml:"jitter"`         // seconds, overrides the receiver's jitter
+	CacheTTL       int       `yaml:"cacheTTL"`       // seconds, responses are reused by queries with the same request, 0 means no cache
 }
 
 type Resource struct {
@@ -36,27 +51,71 @@ type Rule struct {
 	Query              string       `yaml:"query"`
 	QueryParameters    []Attribute  `yaml:"queryParameters"`
 	QueryPostData      *string      `yaml:"queryPostData"`
+	Paginate           *Paginate    `yaml:"paginate"`
+	Format             string       `yaml:"format"` // response format - json (default), xml, ndjson, csv, prometheus, or registered decoder
 	ResourceAttributes []Attribute  `yaml:"resourceAttributes"`
 	ItemAttributes     []Attribute  `yaml:"itemAttributes"`
 	Reducers           []string     `yaml:"reducers"`
 	ReducerMaps        []ReducerMap `yaml:"reducerMaps"`
+	MaxConcurrency     int          `yaml:"maxConcurrency"` // ForEach items processed in parallel
+	Children           []Rule       `yaml:"children"`       // rules evaluated against the current document
+	When               string       `yaml:"when"`           // expression, the rule is skipped when it evaluates to false
+	Filters            []Filter     `yaml:"filters"`        // joined by AND, evaluated before the rule's query, the rule is skipped when not passed
+	OnError            ErrorPolicy  `yaml:"onError"`        // when the query fails - skip (default), abort, or useDefault
+	Default            any          `yaml:"default"`        // document used by useDefault, default is empty object
 }
 
 type MetricEmit struct {
 	Name               string            `yaml:"name"`
+	NameFrom           string            `yaml:"nameFrom"` // expression returning metric name, overrides name
 	Description        string            `yaml:"description"`
-	Filters            []Filter          `yaml:"filters"` // filters are joined by AND
+	DescriptionFrom    string            `yaml:"descriptionFrom"` // expression returning description, overrides description
+	Filters            []Filter          `yaml:"filters"`         // filters are joined by AND
 	Unit               string            `yaml:"unit"`
-	Type               MetricType        `yaml:"type"`
-	Monotonic          bool              `yaml:"monotonic"`
-	Temporality        MetricTemporality `yaml:"temporality"`
-	ValueFrom          string            `yaml:"valueFrom"`
+	UnitFrom           string            `yaml:"unitFrom"`         // expression returning unit, overrides unit
+	Type               MetricType        `yaml:"type"`             // gauge, sum, histogram, summary, or rate and delta of a cumulative counter
+	Monotonic          *bool             `yaml:"monotonic"`        // sum only, default true
+	Temporality        MetricTemporality `yaml:"temporality"`      // sum and histogram, default cumulative
+	ValueFrom          string            `yaml:"valueFrom"`        // gauge, sum, rate, and delta
+	BucketBounds       []float64         `yaml:"bucketBounds"`     // histogram, static bucket bounds
+	BucketBoundsFrom   string            `yaml:"bucketBoundsFrom"` // histogram, expression returning list of bounds
+	BucketCountsFrom   string            `yaml:"bucketCountsFrom"` // histogram, expression returning list of counts, one more than bounds
+	Quantiles          []Quantile        `yaml:"quantiles"`        // summary
+	QuantilesFrom      string            `yaml:"quantilesFrom"`    // summary, expression returning map quantile -> value
+	SumFrom            string            `yaml:"sumFrom"`          // histogram and summary
+	CountFrom          string            `yaml:"countFrom"`        // histogram and summary, histogram default is sum of bucket counts
+	TimestampFrom      string            `yaml:"timestampFrom"`    // expression returning data point timestamp, default is now
+	TimestampFormat    string            `yaml:"timestampFormat"`  // epoch_ms, epoch_s, iso8601, or Go layout, default RFC3339
+	Timezone           string            `yaml:"timezone"`         // for timestamps without zone, default UTC
 	ItemAttributes     []Attribute       `yaml:"itemAttributes"`
 	ResourceAttributes []Attribute       `yaml:"resourceAttributes"`
+	OnError            ErrorPolicy       `yaml:"onError"`        // when an expression fails - skip (default), abort, or useDefault
+	Default            any               `yaml:"default"`        // gauge and sum value used by useDefault, default is 0
+	StaleIntervals     int               `yaml:"staleIntervals"` // rate and delta, series not seen for this many intervals are forgotten, default 5
+	ForEachGroup       string            `yaml:"forEachGroup"`   // reducer whose groups emit one data point each, reducerMap returns values of the group
+	GroupAttribute     string            `yaml:"groupAttribute"` // item attribute carrying the group key, default group
+	EmitEach           *EmitEach         `yaml:"emitEach"`       // gauge, sum, rate, and delta, every numeric field of the object is a metric
 	// ExpressionOnVal    string            `yaml:"expressionOnVal"`
 	// TODO - check if the above can be removed ^^^
 }
 
+// EmitEach - emits every numeric field of the selected objects as its own metric named by the prefix
+// and the field name, e.g. unicastAvg of eqptIngrBytes5min. Expressions of the emit are evaluated on the
+// selected object, params["field"] is the field name, valueFrom is not used.
+type EmitEach struct {
+	Select          string            `yaml:"select"`          // objects whose fields are emitted, default is the current document
+	NamePrefix      string            `yaml:"namePrefix"`      // prepended to the field name, e.g. aci.interface.ingress.
+	Include         string            `yaml:"include"`         // regex, only matching field names are emitted
+	Exclude         string            `yaml:"exclude"`         // regex, matching field names are not emitted
+	SuffixAttribute string            `yaml:"suffixAttribute"` // item attribute for the APIC suffix, e.g. unicastAvg is unicast with aggregation=avg
+	Suffixes        map[string]string `yaml:"suffixes"`        // field name suffix -> attribute value, default Avg, Max, and Min
+}
+
+type Quantile struct {
+	Quantile  float64 `yaml:"quantile"` // 0.0 - 1.0
+	ValueFrom string  `yaml:"valueFrom"`
+}
+
 type DBEmit struct {
 	Name        string      `yaml:"name"`
 	Description string      `yaml:"description"`
@@ -67,19 +126,29 @@ type DBEmit struct {
 }
 
 type LogEmit struct {
-	Filters            []Filter    `yaml:"filters"`       // filters are joined by AND
-	LogType            string      `yaml:"logType"`       // fault, event, or audit
-	MessageFrom        string      `yaml:"messageFrom"`   // expression returning the whole message
-	SeverityFrom       string      `yaml:"severityFrom"`  // expression returning string with services' severity
-	TimestampFrom      string      `yaml:"timestampFrom"` // expression returning log entry timestamp
+	Filters            []Filter    `yaml:"filters"`            // filters are joined by AND
+	LogType            string      `yaml:"logType"`            // fault, event, or audit
+	LogTypeAttribute   string      `yaml:"logTypeAttribute"`   // attribute carrying logType, default log.type, e.g. event.name
+	MessageFrom        string      `yaml:"messageFrom"`        // expression returning the whole message
+	BodyFrom           string      `yaml:"bodyFrom"`           // expression returning structured body, e.g. JSON subtree, overrides messageFrom
+	SeverityFrom       string      `yaml:"severityFrom"`       // expression returning string with services' severity
+	SeverityNumberFrom string      `yaml:"severityNumberFrom"` // expression returning OTel severity number 1-24, overrides severity convertor
+	TimestampFrom      string      `yaml:"timestampFrom"`      // expression returning log entry timestamp
+	TimestampFormat    string      `yaml:"timestampFormat"`    // epoch_ms, epoch_s, iso8601, or Go layout, default RFC3339
+	Timezone           string      `yaml:"timezone"`           // for timestamps without zone, default UTC
+	TraceIdFrom        string      `yaml:"traceIdFrom"`        // expression returning hex encoded trace id
+	SpanIdFrom         string      `yaml:"spanIdFrom"`         // expression returning hex encoded span id
 	ItemAttributes     []Attribute `yaml:"itemAttributes"`
 	ResourceAttributes []Attribute `yaml:"resourceAttributes"`
+	OnError            ErrorPolicy `yaml:"onError"` // when an expression fails - skip (default), abort, or useDefault
+	Default            any         `yaml:"default"` // body used by useDefault, default is empty string
 }
 
 type ReducerMap struct {
 	Name      string   `yaml:"name"`
 	Value     *float64 `yaml:"value"`
 	ValueFrom *string  `yaml:"valueFrom"`
+	GroupBy   *string  `yaml:"groupBy"` // key of the group the value is added to, e.g. pod of the node
 }
 
 type Filter struct {
@@ -88,9 +157,10 @@ type Filter struct {
 }
 
 type Attribute struct {
-	Name      string `yaml:"name"`
-	Value     string `yaml:"value"`
-	ValueFrom string `yaml:"valueFrom"`
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value"`
+	ValueFrom string        `yaml:"valueFrom"`
+	Type      AttributeType `yaml:"type"` // string, int, double, or bool, default keeps the type of the value
 }
 
 type MetricType string
@@ -99,10 +169,33 @@ type MetricTemporality string
 const (
 	Sum        MetricType        = "sum"
 	Gauge      MetricType        = "gauge"
+	Histogram  MetricType        = "histogram"
+	Summary    MetricType        = "summary"
+	Rate       MetricType        = "rate"  // per second rate of a cumulative counter, emitted as gauge
+	DeltaValue MetricType        = "delta" // change of a cumulative counter since the last scrape, emitted as delta sum
 	Cumulative MetricTemporality = "cumulative"
 	Delta      MetricTemporality = "delta"
 )
 
+func (t MetricType) IsValid() bool {
+	switch t {
+	case Sum, Gauge, Histogram, Summary, Rate, DeltaValue:
+		return true
+	}
+	return false
+}
+
+func (m *MetricEmit) isMonotonic() bool {
+	return m.Monotonic == nil || *m.Monotonic
+}
+
+func (m *MetricEmit) aggregationTemporality() pmetric.AggregationTemporality {
+	if m.Temporality == Delta {
+		return pmetric.AggregationTemporalityDelta
+	}
+	return pmetric.AggregationTemporalityCumulative
+}
+
 func NewScraperConfig() Config {
 	return Config{
 		Queries: []*Query{},
@@ -110,13 +203,56 @@ func NewScraperConfig() Config {
 }
 
 func (c *Config) AddQueryRules(rules []byte) error {
+	return c.AddQueryRulesFrom("queries", rules)
+}
+
+// AddQueryRulesFrom - expands templates and fragments, validates and adds queries, source is usually
+// the file name and prefixes the errors together with line and column
+func (c *Config) AddQueryRulesFrom(source string, rules []byte) error {
+	expander := newExpander()
+	root, err := expander.expandQueryFile(source, rules)
+	if err != nil || root == nil {
+		return err
+	}
+	v, err := newValidator(source, expander.origins)
+	if err != nil {
+		return err
+	}
+	if err = v.validate(root); err != nil {
+		return err
+	}
+	expanded, err := yamlv3.Marshal(root)
+	if err != nil {
+		return fmt.Errorf("%s: cannot write expanded rule config file - %v", source, err)
+	}
+
 	rulesParsed := &Config{}
-	err := yaml.Unmarshal(rules, rulesParsed)
+	err = yaml.Unmarshal(expanded, rulesParsed)
 	if err != nil {
-		return fmt.Errorf("config queries: cannot parse rule config file %s - %v", string(rules), err)
+		return fmt.Errorf("%s: cannot parse rule config file - %v", source, err)
 	}
 	for _, q := range rulesParsed.Queries {
-		c.Queries = append(c.Queries, q)
+		if err := q.validateSchedule(); err != nil {
+			return fmt.Errorf("%s: %v", source, err)
+		}
 	}
+	c.Queries = append(c.Queries, rulesParsed.Queries...)
+	c.tableRefs = append(c.tableRefs, v.tables...)
+	c.includes = append(c.includes, expander.includes...)
 	return nil
 }
+
+// AddInlineQueries - adds queries given in the collector's config, e.g. inlineQueries of the receiver,
+// lines in errors are lines of the queries written as YAML
+func (c *Config) AddInlineQueries(source string, queries map[string]any) error {
+	rules, err := yamlv3.Marshal(queries)
+	if err != nil {
+		return fmt.Errorf("%s: cannot read inline queries - %v", source, err)
+	}
+	return c.AddQueryRulesFrom(source, rules)
+}
+
+// Includes - files included by the query files, e.g. to watch them for changes
+func (c *Config) Includes() []string {
+	return c.includes
+}
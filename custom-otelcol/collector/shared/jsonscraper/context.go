@@ -1,9 +1,12 @@
 package jsonscraper
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/antchfx/jsonquery"
+	expr "github.com/chrlic/otelcol-cust/collector/shared/expressions"
 )
 
 type scraperContext struct {
@@ -12,6 +15,28 @@ type scraperContext struct {
 	itemAttrsStack Stack[map[string]any]
 	scopeStack     Stack[*Scope]
 	paramStack     Stack[map[string]any]
+	reducersStack  Stack[*expr.Reducers]
+	// set for parallel branches - emits are kept here and flushed in the serial order
+	pending *pendingEmits
+	// set for parallel branches - values added to reducers of the enclosing rules, merged in the serial order
+	reducerAdds *pendingReducerAdds
+	// reducers declared at stack levels below this one belong to the enclosing rules
+	branchLevel int
+	// query wide limit of parallel branches, nil means no limit
+	slots *branchSlots
+	query *Query
+	// cancelled when the scrape times out or the scraper stops
+	runCtx context.Context
+	// seconds between two scrapes of the query
+	interval int
+	// data emitted by the scrape, nil means emit right away
+	batch *emitBatch
+	// path of the rule being run, e.g. rules/children[1]/forEach, for telemetry
+	rulePath string
+	// failed requests of the query's scrape, shared with parallel branches
+	failures *atomic.Int64
+	// stops the scrape of the query, used by onError abort
+	abort context.CancelCauseFunc
 }
 
 func newScaperContext() scraperContext {
@@ -21,9 +46,79 @@ func newScaperContext() scraperContext {
 		itemAttrsStack: *NewStack[map[string]any](),
 		scopeStack:     *NewStack[*Scope](),
 		paramStack:     *NewStack[map[string]any](),
+		reducersStack:  *NewStack[*expr.Reducers](),
+		runCtx:         context.Background(),
+		failures:       &atomic.Int64{},
 	}
 }
 
+// branch - returns copy of the context for a parallel branch. Stack levels of the parent
+// are shared read only, the branch pushes its own levels and buffers its own emits.
+func (ctx *scraperContext) branch() *scraperContext {
+	return &scraperContext{
+		docStack:       *ctx.docStack.Clone(),
+		rsrcAttrsStack: *ctx.rsrcAttrsStack.Clone(),
+		itemAttrsStack: *ctx.itemAttrsStack.Clone(),
+		scopeStack:     *ctx.scopeStack.Clone(),
+		paramStack:     *ctx.paramStack.Clone(),
+		reducersStack:  *ctx.reducersStack.Clone(),
+		pending:        &pendingEmits{},
+		reducerAdds:    &pendingReducerAdds{},
+		branchLevel:    len(ctx.reducersStack.keys),
+		slots:          ctx.slots,
+		query:          ctx.query,
+		runCtx:         ctx.runCtx,
+		interval:       ctx.interval,
+		batch:          ctx.batch,
+		rulePath:       ctx.rulePath,
+		failures:       ctx.failures,
+		abort:          ctx.abort,
+	}
+}
+
+// snapshot - returns single level copy of the context, used by emits deferred
+// after the context was already popped
+func (ctx *scraperContext) snapshot() *scraperContext {
+	snap := newScaperContext()
+	snap.push()
+	doc, _ := ctx.docStack.Top()
+	snap.setDoc(doc)
+	snap.setScope(ctx.getScope())
+	snap.rsrcAttrsStack.SetTop(ctx.getRsrcAttrs())
+	snap.itemAttrsStack.SetTop(ctx.getItemAttrs())
+	snap.paramStack.SetTop(ctx.getParameters())
+	snap.reducersStack.SetTop(ctx.getReducers())
+	snap.interval = ctx.interval
+	snap.batch = ctx.batch
+	return &snap
+}
+
+// emit - runs emit function immediately, or keeps it with a snapshot of the context
+// for later if the context is a parallel branch
+func (ctx *scraperContext) emit(emitFunc func(emitContext *scraperContext)) {
+	if ctx.pending == nil {
+		emitFunc(ctx)
+		return
+	}
+	snap := ctx.snapshot()
+	*ctx.pending = append(*ctx.pending, func() { emitFunc(snap) })
+}
+
+// mergeEmits - takes over emits of a finished branch
+func (ctx *scraperContext) mergeEmits(branch *scraperContext) {
+	if branch.pending == nil {
+		return
+	}
+	for _, emitFunc := range *branch.pending {
+		if ctx.pending == nil {
+			emitFunc()
+		} else {
+			*ctx.pending = append(*ctx.pending, emitFunc)
+		}
+	}
+	branch.pending = nil
+}
+
 func (ctx *scraperContext) cleanup() {
 
 }
@@ -34,6 +129,7 @@ func (ctx *scraperContext) push() {
 	ctx.rsrcAttrsStack.Push(map[string]any{})
 	ctx.itemAttrsStack.Push(map[string]any{})
 	ctx.paramStack.Push(map[string]any{})
+	ctx.reducersStack.Push(nil)
 }
 
 func (ctx *scraperContext) pop() {
@@ -42,6 +138,7 @@ func (ctx *scraperContext) pop() {
 	ctx.rsrcAttrsStack.Pop()
 	ctx.itemAttrsStack.Pop()
 	ctx.paramStack.Pop()
+	ctx.reducersStack.Pop()
 }
 
 func (ctx *scraperContext) setDoc(doc *jsonquery.Node) {
@@ -52,6 +149,10 @@ func (ctx *scraperContext) setScope(scope *Scope) {
 	ctx.scopeStack.SetTop(scope)
 }
 
+func (ctx *scraperContext) setReducers(reducers *expr.Reducers) {
+	ctx.reducersStack.SetTop(reducers)
+}
+
 func (ctx *scraperContext) addRsrcAttr(name string, value any) bool {
 	rsrcMap, exists := ctx.rsrcAttrsStack.Top()
 	if !exists {
@@ -115,6 +216,67 @@ func (ctx *scraperContext) getParameters() map[string]any {
 	)
 }
 
+// addToReducer - adds value to the reducer map declared by the rule or its parents. Reducers of rules
+// enclosing a parallel branch get the value when the branch is merged, so they see values in the
+// order of a serial run.
+func (ctx *scraperContext) addToReducer(name string, group string, value any) error {
+	level := -1
+	for i, reducers := range ctx.reducersStack.keys {
+		if reducers != nil && reducers.Declares(name) {
+			level = i
+		}
+	}
+	if level < 0 {
+		return fmt.Errorf("reducer %s is not declared", name)
+	}
+	add := reducerAdd{
+		reducers: ctx.reducersStack.keys[level],
+		level:    level,
+		name:     name,
+		group:    group,
+		value:    value,
+	}
+	if level >= ctx.branchLevel {
+		return add.apply()
+	}
+	*ctx.reducerAdds = append(*ctx.reducerAdds, add)
+	return nil
+}
+
+// mergeReducerAdds - takes over reducer values of a finished branch
+func (ctx *scraperContext) mergeReducerAdds(branch *scraperContext) error {
+	if branch.reducerAdds == nil {
+		return nil
+	}
+	var err error
+	for _, add := range *branch.reducerAdds {
+		if add.level >= ctx.branchLevel {
+			if addErr := add.apply(); addErr != nil {
+				err = addErr
+			}
+		} else {
+			*ctx.reducerAdds = append(*ctx.reducerAdds, add)
+		}
+	}
+	branch.reducerAdds = nil
+	return err
+}
+
+// getReducers - reducers of the nearest rule declaring some, nil if there is none
+func (ctx *scraperContext) getReducers() *expr.Reducers {
+	return ctx.reducersStack.Reduce(
+		func() *expr.Reducers {
+			return nil
+		},
+		func(accum *expr.Reducers, added *expr.Reducers) *expr.Reducers {
+			if added != nil {
+				return added
+			}
+			return accum
+		},
+	)
+}
+
 func (ctx *scraperContext) getScope() *Scope {
 	return ctx.scopeStack.Reduce(
 		func() *Scope {
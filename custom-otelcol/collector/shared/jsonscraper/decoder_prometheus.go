@@ -0,0 +1,144 @@
+package jsonscraper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var prometheusSuffixes = []string{"_bucket", "_sum", "_count", "_total", "_created"}
+
+// decodePrometheus - converts text exposition format into list of samples
+// {"name", "family", "type", "help", "labels": {...}, "value", "timestamp"},
+// value is kept as string so that NaN and +Inf survive, timestamp is present only when exposed
+func decodePrometheus(response []byte) (any, error) {
+	types := map[string]string{}
+	helps := map[string]string{}
+	samples := []any{}
+
+	for lineNo, line := range strings.Split(string(response), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			fields := strings.SplitN(strings.TrimSpace(line[1:]), " ", 3)
+			if len(fields) == 3 {
+				switch fields[0] {
+				case "TYPE":
+					types[fields[1]] = strings.TrimSpace(fields[2])
+				case "HELP":
+					helps[fields[1]] = fields[2]
+				}
+			}
+			continue
+		}
+
+		sample, err := parsePrometheusSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d - %v", lineNo+1, err)
+		}
+		family := prometheusFamily(sample["name"].(string), types)
+		sample["family"] = family
+		sample["type"] = types[family]
+		sample["help"] = helps[family]
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// prometheusFamily - returns metric family of the sample, e.g. http_latency for http_latency_bucket
+func prometheusFamily(name string, types map[string]string) string {
+	if _, ok := types[name]; ok {
+		return name
+	}
+	for _, suffix := range prometheusSuffixes {
+		family := strings.TrimSuffix(name, suffix)
+		if _, ok := types[family]; ok && family != name {
+			return family
+		}
+	}
+	return name
+}
+
+func parsePrometheusSample(line string) (map[string]any, error) {
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return nil, fmt.Errorf("sample without value %s", line)
+	}
+	sample := map[string]any{"name": line[:nameEnd]}
+	labels := map[string]any{}
+	rest := line[nameEnd:]
+
+	if rest[0] == '{' {
+		i := 1
+		for {
+			for i < len(rest) && (rest[i] == ' ' || rest[i] == ',') {
+				i++
+			}
+			if i >= len(rest) {
+				return nil, fmt.Errorf("unterminated labels %s", line)
+			}
+			if rest[i] == '}' {
+				i++
+				break
+			}
+			eq := strings.IndexByte(rest[i:], '=')
+			if eq < 0 || i+eq+1 >= len(rest) || rest[i+eq+1] != '"' {
+				return nil, fmt.Errorf("invalid label in %s", line)
+			}
+			name := strings.TrimSpace(rest[i : i+eq])
+			value, end, err := parsePrometheusLabelValue(rest, i+eq+2)
+			if err != nil {
+				return nil, fmt.Errorf("label %s in %s - %v", name, line, err)
+			}
+			labels[name] = value
+			i = end
+		}
+		rest = rest[i:]
+	}
+	sample["labels"] = labels
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid value in %s", line)
+	}
+	if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil, fmt.Errorf("value %s is not a number", fields[0])
+	}
+	sample["value"] = fields[0]
+	if len(fields) == 2 {
+		timestamp, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp %s is not a number", fields[1])
+		}
+		sample["timestamp"] = timestamp
+	}
+	return sample, nil
+}
+
+// parsePrometheusLabelValue - reads quoted value starting after the opening quote,
+// returns the value and index after the closing quote
+func parsePrometheusLabelValue(s string, start int) (string, int, error) {
+	value := strings.Builder{}
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), i + 1, nil
+		case '\\':
+			i++
+			if i >= len(s) {
+				break
+			}
+			switch s[i] {
+			case 'n':
+				value.WriteByte('\n')
+			default:
+				value.WriteByte(s[i])
+			}
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated value")
+}
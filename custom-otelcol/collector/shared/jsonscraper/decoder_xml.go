@@ -0,0 +1,74 @@
+package jsonscraper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type xmlElement struct {
+	name     string
+	value    map[string]any
+	children []any
+	text     strings.Builder
+}
+
+// decodeXML - converts XML document the way APIC converts its objects to JSON,
+// <fabricNode name="leaf1"><eqptCh/></fabricNode> becomes
+// {"fabricNode": {"attributes": {"name": "leaf1"}, "children": [{"eqptCh": {}}]}}.
+// Non-blank text content is kept in "text".
+func decodeXML(response []byte) (any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(response))
+	stack := []*xmlElement{}
+	var root map[string]any
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &xmlElement{name: t.Name.Local, value: map[string]any{}}
+			if len(t.Attr) > 0 {
+				attributes := map[string]any{}
+				for _, attr := range t.Attr {
+					attributes[attr.Name.Local] = attr.Value
+				}
+				element.value["attributes"] = attributes
+			}
+			stack = append(stack, element)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			element := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(element.children) > 0 {
+				element.value["children"] = element.children
+			}
+			if text := strings.TrimSpace(element.text.String()); text != "" {
+				element.value["text"] = text
+			}
+			wrapped := map[string]any{element.name: element.value}
+			if len(stack) == 0 {
+				root = wrapped
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, wrapped)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
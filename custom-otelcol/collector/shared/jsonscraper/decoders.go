@@ -0,0 +1,128 @@
+package jsonscraper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/jsonquery"
+)
+
+const (
+	FormatJSON       = "json"       // default
+	FormatXML        = "xml"        // elements as {"name": {"attributes": {...}, "children": [...], "text": "..."}}, like APIC JSON
+	FormatNDJSON     = "ndjson"     // one JSON value per line, list of values
+	FormatCSV        = "csv"        // header row with column names, list of objects
+	FormatPrometheus = "prometheus" // text exposition format, list of samples
+)
+
+// Decoder - converts response body into JSON compatible structure of maps, slices and scalars
+type Decoder func(response []byte) (any, error)
+
+var decoders = struct {
+	sync.RWMutex
+	registry map[string]Decoder
+}{
+	registry: map[string]Decoder{
+		FormatJSON:       decodeJSON,
+		FormatXML:        decodeXML,
+		FormatNDJSON:     decodeNDJSON,
+		FormatCSV:        decodeCSV,
+		FormatPrometheus: decodePrometheus,
+	},
+}
+
+// RegisterDecoder - adds decoder for the response format used in rule's format option, replaces existing one
+func RegisterDecoder(format string, decoder Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+	decoders.registry[format] = decoder
+}
+
+func lookupDecoder(format string) (Decoder, error) {
+	if format == "" {
+		format = FormatJSON
+	}
+	decoders.RLock()
+	defer decoders.RUnlock()
+	decoder, ok := decoders.registry[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown response format %s", format)
+	}
+	return decoder, nil
+}
+
+// decodeResponse - converts response in given format into JSON compatible structure
+func decodeResponse(format string, response string) (any, error) {
+	decoder, err := lookupDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	return decoder([]byte(response))
+}
+
+// parseResponse - converts response in given format into node tree for select and jq functions
+func parseResponse(format string, response string) (*jsonquery.Node, error) {
+	if format == "" || format == FormatJSON {
+		return jsonquery.Parse(strings.NewReader(response))
+	}
+	decoded, err := decodeResponse(format, response)
+	if err != nil {
+		return nil, err
+	}
+	return toNode(decoded)
+}
+
+// toNode - converts JSON compatible structure into node tree
+func toNode(value any) (*jsonquery.Node, error) {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonquery.Parse(bytes.NewReader(valueBytes))
+}
+
+func decodeJSON(response []byte) (any, error) {
+	var value any
+	err := json.Unmarshal(response, &value)
+	return value, err
+}
+
+func decodeNDJSON(response []byte) (any, error) {
+	values := []any{}
+	for lineNo, line := range bytes.Split(response, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(line, &value); err != nil {
+			return nil, fmt.Errorf("line %d - %v", lineNo+1, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func decodeCSV(response []byte) (any, error) {
+	records, err := csv.NewReader(bytes.NewReader(response)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := []any{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := map[string]any{}
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
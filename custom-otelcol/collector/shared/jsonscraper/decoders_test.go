@@ -0,0 +1,164 @@
+package jsonscraper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/antchfx/jsonquery"
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+)
+
+func findValues(t *testing.T, format string, response string, path string) []any {
+	doc, err := parseResponse(format, response)
+	if err != nil {
+		t.Fatalf("Cannot parse %s response - %v", format, err)
+	}
+	values := []any{}
+	for _, node := range jsonquery.Find(doc, path) {
+		values = append(values, node.Value())
+	}
+	return values
+}
+
+func TestDecodeXML(t *testing.T) {
+	response := `<?xml version="1.0" encoding="UTF-8"?>
+<imdata totalCount="2">
+  <fabricNode name="leaf1" role="leaf"><eqptCh dn="topology/pod-1/node-101/sys/ch"/></fabricNode>
+  <fabricNode name="spine1" role="spine"><descr>Spine switch</descr></fabricNode>
+</imdata>`
+
+	names := findValues(t, FormatXML, response, "imdata//fabricNode/attributes/name")
+	if !reflect.DeepEqual(names, []any{"leaf1", "spine1"}) {
+		t.Fatalf("expected: [leaf1 spine1] != actual: %v", names)
+	}
+	dns := findValues(t, FormatXML, response, "//fabricNode//eqptCh/attributes/dn")
+	if !reflect.DeepEqual(dns, []any{"topology/pod-1/node-101/sys/ch"}) {
+		t.Fatalf("unexpected child dn %v", dns)
+	}
+	texts := findValues(t, FormatXML, response, "//descr/text")
+	if !reflect.DeepEqual(texts, []any{"Spine switch"}) {
+		t.Fatalf("unexpected text %v", texts)
+	}
+}
+
+func TestDecodeNDJSONAndCSV(t *testing.T) {
+	names := findValues(t, FormatNDJSON, "{\"name\":\"a\"}\n\n{\"name\":\"b\"}\n", "*/name")
+	if !reflect.DeepEqual(names, []any{"a", "b"}) {
+		t.Fatalf("expected: [a b] != actual: %v", names)
+	}
+
+	names = findValues(t, FormatCSV, "name,speed\neth1/1,10G\neth1/2,\"1G\"\n", "*/speed")
+	if !reflect.DeepEqual(names, []any{"10G", "1G"}) {
+		t.Fatalf("expected: [10G 1G] != actual: %v", names)
+	}
+
+	if _, err := parseResponse(FormatNDJSON, "{\"name\":\"a\"}\n{broken\n"); err == nil {
+		t.Fatalf("expected error for broken ndjson line")
+	}
+	if _, err := parseResponse("yaml", "a: b"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestDecodePrometheus(t *testing.T) {
+	response := `# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{method="get",path="/a\"b"} 1027 1395066363000
+http_requests_total{method="post",path="/"} 3
+# TYPE latency histogram
+latency_bucket{le="0.5"} 10
+latency_bucket{le="+Inf"} 12
+latency_sum 3.5
+up NaN
+`
+	decoded, err := decodeResponse(FormatPrometheus, response)
+	if err != nil {
+		t.Fatalf("Cannot decode - %v", err)
+	}
+	samples := decoded.([]any)
+	if len(samples) != 6 {
+		t.Fatalf("expected: 6 samples != actual: %d", len(samples))
+	}
+	first := samples[0].(map[string]any)
+	expected := map[string]any{
+		"name":      "http_requests_total",
+		"family":    "http_requests_total",
+		"type":      "counter",
+		"help":      "Requests served.",
+		"labels":    map[string]any{"method": "get", "path": `/a"b`},
+		"value":     "1027",
+		"timestamp": int64(1395066363000),
+	}
+	if !reflect.DeepEqual(first, expected) {
+		t.Fatalf("expected: %v != actual: %v", expected, first)
+	}
+	bucket := samples[3].(map[string]any)
+	if bucket["family"] != "latency" || bucket["type"] != "histogram" || bucket["labels"].(map[string]any)["le"] != "+Inf" {
+		t.Fatalf("unexpected bucket sample %v", bucket)
+	}
+
+	if _, err := decodeResponse(FormatPrometheus, `broken{le="1} 1`); err == nil {
+		t.Fatalf("expected error for unterminated label")
+	}
+}
+
+const decodersTestQueries = `
+queries:
+- name: Exporter
+  resource:
+    name: node
+  scope:
+    name: test
+  rules:
+    query: /metrics
+    format: prometheus
+    select: "*[family='http_requests_total']"
+    forEach:
+      itemAttributes:
+      - name: method
+        valueFrom: labels/method
+      emitMetric:
+      - name: http.requests
+        type: sum
+        valueFrom: value
+`
+
+func TestPrometheusRule(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(decodersTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/metrics": "# TYPE http_requests_total counter\nhttp_requests_total{method=\"get\"} 5\nhttp_requests_total{method=\"post\"} 7\nup 1\n",
+		},
+	}
+
+	sink := &batchSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	values := map[string]float64{}
+	for _, bundle := range sink.bundles {
+		dps := bundle.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			method, _ := dps.At(i).Attributes().Get("method")
+			values[method.Str()] = dps.At(i).DoubleValue()
+		}
+	}
+	if !reflect.DeepEqual(values, map[string]float64{"get": 5, "post": 7}) {
+		t.Fatalf("expected: get 5, post 7 != actual: %v", values)
+	}
+}
@@ -0,0 +1,225 @@
+package jsonscraper
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/jsonquery"
+)
+
+type histogramValue struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type quantileValue struct {
+	quantile float64
+	value    float64
+}
+
+type summaryValue struct {
+	quantiles []quantileValue
+	sum       float64
+	count     uint64
+}
+
+func (g *Scraper) evaluateHistogram(emit *MetricEmit, doc *jsonquery.Node, scContext *scraperContext) (*histogramValue, error) {
+	bounds := emit.BucketBounds
+	if emit.BucketBoundsFrom != "" {
+		var err error
+		bounds, err = g.evaluateNumberList(doc, emit.BucketBoundsFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("bucket bounds - %v", err)
+		}
+	}
+	if !sort.Float64sAreSorted(bounds) {
+		return nil, fmt.Errorf("bucket bounds %v are not sorted", bounds)
+	}
+
+	countsFloat, err := g.evaluateNumberList(doc, emit.BucketCountsFrom, scContext)
+	if err != nil {
+		return nil, fmt.Errorf("bucket counts - %v", err)
+	}
+	if len(countsFloat) != len(bounds)+1 {
+		return nil, fmt.Errorf("%d bucket counts do not match %d bounds, expected %d counts", len(countsFloat), len(bounds), len(bounds)+1)
+	}
+
+	value := &histogramValue{
+		bounds: bounds,
+		counts: make([]uint64, len(countsFloat)),
+	}
+	for i, c := range countsFloat {
+		if c < 0 {
+			return nil, fmt.Errorf("bucket count %v is negative", c)
+		}
+		value.counts[i] = uint64(c)
+		value.count += uint64(c)
+	}
+
+	if emit.CountFrom != "" {
+		count, err := g.evaluateNumber(doc, emit.CountFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("count - %v", err)
+		}
+		value.count = uint64(count)
+	}
+	value.sum, err = g.evaluateNumber(doc, emit.SumFrom, scContext)
+	if err != nil {
+		return nil, fmt.Errorf("sum - %v", err)
+	}
+
+	return value, nil
+}
+
+func (g *Scraper) evaluateSummary(emit *MetricEmit, doc *jsonquery.Node, scContext *scraperContext) (*summaryValue, error) {
+	value := &summaryValue{}
+
+	for _, q := range emit.Quantiles {
+		v, err := g.evaluateNumber(doc, q.ValueFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("quantile %v - %v", q.Quantile, err)
+		}
+		value.quantiles = append(value.quantiles, quantileValue{quantile: q.Quantile, value: v})
+	}
+
+	if emit.QuantilesFrom != "" {
+		quantilesAny, err := g.evaluateRawValueFrom(doc, emit.QuantilesFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("quantiles - %v", err)
+		}
+		quantiles, err := toQuantiles(quantilesAny)
+		if err != nil {
+			return nil, fmt.Errorf("quantiles - %v", err)
+		}
+		value.quantiles = append(value.quantiles, quantiles...)
+	}
+
+	for _, q := range value.quantiles {
+		if q.quantile < 0 || q.quantile > 1 {
+			return nil, fmt.Errorf("quantile %v out of range 0.0 - 1.0", q.quantile)
+		}
+	}
+	sort.Slice(value.quantiles, func(i, j int) bool {
+		return value.quantiles[i].quantile < value.quantiles[j].quantile
+	})
+
+	count, err := g.evaluateNumber(doc, emit.CountFrom, scContext)
+	if err != nil {
+		return nil, fmt.Errorf("count - %v", err)
+	}
+	value.count = uint64(count)
+	value.sum, err = g.evaluateNumber(doc, emit.SumFrom, scContext)
+	if err != nil {
+		return nil, fmt.Errorf("sum - %v", err)
+	}
+
+	return value, nil
+}
+
+// evaluateNumber - evaluates expression returning number, empty expression returns 0
+func (g *Scraper) evaluateNumber(doc *jsonquery.Node, expr string, scContext *scraperContext) (float64, error) {
+	if expr == "" {
+		return 0, nil
+	}
+	valueAny, err := g.evaluateRawValueFrom(doc, expr, scContext)
+	if err != nil {
+		return 0, err
+	}
+	return toFloat(valueAny)
+}
+
+// evaluateNumberList - evaluates expression returning list of numbers
+func (g *Scraper) evaluateNumberList(doc *jsonquery.Node, expr string, scContext *scraperContext) ([]float64, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("expression not set")
+	}
+	valueAny, err := g.evaluateRawValueFrom(doc, expr, scContext)
+	if err != nil {
+		return nil, err
+	}
+	return toFloatList(valueAny)
+}
+
+func toFloat(value any) (float64, error) {
+	if holder, ok := value.(valueHolder); ok {
+		value = holder.Value()
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("%T %v is not number", value, value)
+}
+
+func toFloatList(value any) ([]float64, error) {
+	if holder, ok := value.(valueHolder); ok {
+		value = holder.Value()
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%T %v is not list", value, value)
+	}
+	list := make([]float64, rv.Len())
+	for i := range list {
+		f, err := toFloat(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		list[i] = f
+	}
+	return list, nil
+}
+
+// toQuantiles - converts map quantile -> value, quantile keys may be numbers like 0.95 or percentiles like p95
+func toQuantiles(value any) ([]quantileValue, error) {
+	if holder, ok := value.(valueHolder); ok {
+		value = holder.Value()
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("%T %v is not map", value, value)
+	}
+
+	quantiles := []quantileValue{}
+	iter := rv.MapRange()
+	for iter.Next() {
+		key := iter.Key().Interface()
+		if holder, ok := key.(valueHolder); ok {
+			key = holder.Value()
+		}
+		keyStr := strings.TrimSpace(fmt.Sprintf("%v", key))
+		divider := 1.0
+		if strings.HasPrefix(strings.ToLower(keyStr), "p") {
+			keyStr = keyStr[1:]
+			divider = 100
+		}
+		q, err := strconv.ParseFloat(keyStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("quantile %v is not number", key)
+		}
+		v, err := toFloat(iter.Value().Interface())
+		if err != nil {
+			return nil, fmt.Errorf("quantile %v - %v", key, err)
+		}
+		quantiles = append(quantiles, quantileValue{quantile: q / divider, value: v})
+	}
+	return quantiles, nil
+}
@@ -0,0 +1,138 @@
+package jsonscraper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+const distributionTestQueries = `
+queries:
+- name: Latency
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /latency
+    emitMetric:
+    - name: requests
+      type: sum
+      monotonic: false
+      temporality: delta
+      valueFrom: requests
+    - name: latency
+      type: histogram
+      bucketBounds: [10, 100]
+      bucketCountsFrom: buckets
+      sumFrom: total
+    - name: latency.computed
+      type: histogram
+      bucketBoundsFrom: =[1.0, 2.0]
+      bucketCountsFrom: =[1, 2, 3]
+      countFrom: =10
+    - name: latency.quantiles
+      type: summary
+      quantiles:
+      - quantile: 0.5
+        valueFrom: p50
+      quantilesFrom: percentiles
+      countFrom: count
+      sumFrom: total
+    - name: latency.invalid
+      type: histogram
+      bucketBounds: [10, 100]
+      bucketCountsFrom: =[1, 2]
+`
+
+func scrapeDistributions(t *testing.T) map[string]pmetric.Metric {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(distributionTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/latency": `{"requests":"12","buckets":["5","4","3"],"total":"1234.5","count":"12","p50":"20","percentiles":{"p99":"300","0.9":"150"}}`,
+		},
+	}
+
+	sink := &batchSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	metrics := map[string]pmetric.Metric{}
+	for _, bundle := range sink.bundles {
+		ms := bundle.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < ms.Len(); i++ {
+			metrics[ms.At(i).Name()] = ms.At(i)
+		}
+	}
+	return metrics
+}
+
+func TestSumTemporality(t *testing.T) {
+	metrics := scrapeDistributions(t)
+	sum := metrics["requests"].Sum()
+	if sum.IsMonotonic() || sum.AggregationTemporality() != pmetric.AggregationTemporalityDelta {
+		t.Fatalf("expected non monotonic delta sum, got monotonic %v, %v", sum.IsMonotonic(), sum.AggregationTemporality())
+	}
+	if sum.DataPoints().At(0).DoubleValue() != 12 {
+		t.Fatalf("expected: 12 != actual: %v", sum.DataPoints().At(0).DoubleValue())
+	}
+}
+
+func TestHistogramEmit(t *testing.T) {
+	metrics := scrapeDistributions(t)
+
+	dp := metrics["latency"].Histogram().DataPoints().At(0)
+	if !reflect.DeepEqual(dp.ExplicitBounds().AsRaw(), []float64{10, 100}) || !reflect.DeepEqual(dp.BucketCounts().AsRaw(), []uint64{5, 4, 3}) {
+		t.Fatalf("unexpected buckets %v %v", dp.ExplicitBounds().AsRaw(), dp.BucketCounts().AsRaw())
+	}
+	if dp.Count() != 12 || dp.Sum() != 1234.5 {
+		t.Fatalf("expected: count 12, sum 1234.5 != actual: %d, %v", dp.Count(), dp.Sum())
+	}
+	if metrics["latency"].Histogram().AggregationTemporality() != pmetric.AggregationTemporalityCumulative {
+		t.Fatalf("expected cumulative histogram")
+	}
+
+	dp = metrics["latency.computed"].Histogram().DataPoints().At(0)
+	if dp.Count() != 10 || dp.BucketCounts().Len() != 3 {
+		t.Fatalf("expected: count 10 with 3 buckets != actual: %d, %v", dp.Count(), dp.BucketCounts().AsRaw())
+	}
+
+	if _, ok := metrics["latency.invalid"]; ok {
+		t.Fatalf("histogram with wrong number of buckets should not be emitted")
+	}
+}
+
+func TestSummaryEmit(t *testing.T) {
+	metrics := scrapeDistributions(t)
+
+	dp := metrics["latency.quantiles"].Summary().DataPoints().At(0)
+	actual := []float64{}
+	for i := 0; i < dp.QuantileValues().Len(); i++ {
+		q := dp.QuantileValues().At(i)
+		actual = append(actual, q.Quantile(), q.Value())
+	}
+	expected := []float64{0.5, 20, 0.9, 150, 0.99, 300}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %v != actual: %v", expected, actual)
+	}
+	if dp.Count() != 12 || dp.Sum() != 1234.5 {
+		t.Fatalf("expected: count 12, sum 1234.5 != actual: %d, %v", dp.Count(), dp.Sum())
+	}
+}
@@ -0,0 +1,200 @@
+package jsonscraper
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// APIC stats objects carry one counter in several fields, e.g. unicastAvg, unicastMax, and unicastMin
+var defaultSuffixes = map[string]string{
+	"Avg": "avg",
+	"Max": "max",
+	"Min": "min",
+}
+
+// include and exclude regexes of emitEach, compiled once
+var fieldRegexps sync.Map
+
+func fieldRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := fieldRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	fieldRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// resolveMetricEmit - returns copy of the emit with name, unit, and description evaluated from
+// nameFrom, unitFrom, and descriptionFrom, the emit itself when it has none of them
+func (g *Scraper) resolveMetricEmit(emit *MetricEmit, doc *jsonquery.Node, scContext *scraperContext) (*MetricEmit, error) {
+	if emit.NameFrom == "" && emit.UnitFrom == "" && emit.DescriptionFrom == "" {
+		return emit, nil
+	}
+	resolved := *emit
+	fields := []struct {
+		name  string
+		expr  string
+		value *string
+	}{
+		{"name", emit.NameFrom, &resolved.Name},
+		{"unit", emit.UnitFrom, &resolved.Unit},
+		{"description", emit.DescriptionFrom, &resolved.Description},
+	}
+	for _, field := range fields {
+		if field.expr == "" {
+			continue
+		}
+		valueAny, err := g.evaluateValueFrom(doc, field.expr, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate %s of metric %s from %s - %v", field.name, emit.Name, field.expr, err)
+		}
+		*field.value = g.stringifyVal(valueAny)
+	}
+	if resolved.Name == "" {
+		return nil, fmt.Errorf("Metric name from %s is empty", emit.NameFrom)
+	}
+	return &resolved, nil
+}
+
+// processEmitEach - emits every numeric field of the selected objects as its own metric, fields
+// with APIC suffix are emitted under the name without it and the suffix as item attribute
+func (g *Scraper) processEmitEach(emit *MetricEmit, doc *jsonquery.Node, scContext *scraperContext) {
+	each := emit.EmitEach
+	objects := []*jsonquery.Node{doc}
+	if each.Select != "" {
+		objects = jsonquery.Find(doc, each.Select)
+	}
+
+	for _, object := range objects {
+		for _, field := range object.ChildNodes() {
+			value, ok := numericField(field)
+			if !ok {
+				continue
+			}
+			emitted, err := each.emitted(field.Data)
+			if err != nil {
+				g.logger.Sugar().Errorf("Cannot select fields of metric %s - %v", emit.Name, err)
+				return
+			}
+			if !emitted {
+				continue
+			}
+
+			fieldEmit := *emit
+			fieldEmit.EmitEach = nil
+			name, suffix := each.splitSuffix(field.Data)
+			fieldEmit.Name = each.NamePrefix + name
+
+			scContext.push()
+			scContext.addParameter("field", field.Data)
+			if suffix != "" {
+				scContext.addItemAttr(each.SuffixAttribute, suffix)
+			}
+			err = g.processEmitField(&fieldEmit, value, object, scContext)
+			if err != nil && g.handleError(emit.OnError, "", err, scContext) {
+				g.emitDefaultMetric(&fieldEmit, scContext)
+			}
+			scContext.pop()
+			if scContext.runCtx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (g *Scraper) processEmitField(emit *MetricEmit, value float64, object *jsonquery.Node, scContext *scraperContext) error {
+	g.evaluateResourceAttributes(emit.ResourceAttributes, object, scContext)
+	g.evaluateItemAttributes(emit.ItemAttributes, object, scContext)
+	timestamp, err := g.evaluateTimestamp(emit.TimestampFrom, emit.TimestampFormat, emit.Timezone, object, scContext)
+	if err != nil {
+		return fmt.Errorf("Cannot evaluate timestamp of metric %s - %v", emit.Name, err)
+	}
+	emit, err = g.resolveMetricEmit(emit, object, scContext)
+	if err != nil {
+		return err
+	}
+	g.emitNumber(emit, value, timestamp, scContext)
+	return nil
+}
+
+// numericField - returns value of the field if it is a number, or a string with number as APIC sends them
+func numericField(field *jsonquery.Node) (float64, bool) {
+	var value float64
+	switch fieldValue := field.Value().(type) {
+	case float64:
+		value = fieldValue
+	case string:
+		var err error
+		value, err = strconv.ParseFloat(strings.TrimSpace(fieldValue), 64)
+		if err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+// emitted - whether the field passes include and exclude regexes
+func (each *EmitEach) emitted(field string) (bool, error) {
+	if each.Include != "" {
+		include, err := fieldRegexp(each.Include)
+		if err != nil {
+			return false, fmt.Errorf("invalid include %s - %v", each.Include, err)
+		}
+		if !include.MatchString(field) {
+			return false, nil
+		}
+	}
+	if each.Exclude != "" {
+		exclude, err := fieldRegexp(each.Exclude)
+		if err != nil {
+			return false, fmt.Errorf("invalid exclude %s - %v", each.Exclude, err)
+		}
+		if exclude.MatchString(field) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// splitSuffix - returns field name without its APIC suffix and attribute value of the suffix,
+// the longest suffix wins, the name is kept as is without suffixAttribute
+func (each *EmitEach) splitSuffix(field string) (string, string) {
+	if each.SuffixAttribute == "" {
+		return field, ""
+	}
+	suffixes := each.Suffixes
+	if len(suffixes) == 0 {
+		suffixes = defaultSuffixes
+	}
+	names := make([]string, 0, len(suffixes))
+	for suffix := range suffixes {
+		names = append(names, suffix)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	for _, suffix := range names {
+		if len(field) > len(suffix) && strings.HasSuffix(field, suffix) {
+			return strings.TrimSuffix(field, suffix), suffixes[suffix]
+		}
+	}
+	return field, ""
+}
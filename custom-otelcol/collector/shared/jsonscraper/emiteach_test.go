@@ -0,0 +1,114 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+)
+
+const emitEachTestQueries = `
+queries:
+- name: Interfaces
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /stats
+    select: imdata/*
+    forEach:
+      emitMetric:
+      - nameFrom: '="aci.interface." + jqs("eqptIngrBytes5min/attributes/dn").split("/")[1]'
+        unitFrom: =jqs("eqptIngrBytes5min/attributes/unit")
+        descriptionFrom: '="ingress unicast bytes of " + jqs("eqptIngrBytes5min/attributes/dn")'
+        type: gauge
+        valueFrom: eqptIngrBytes5min/attributes/unicastLast
+      - type: gauge
+        unitFrom: '=params["field"].startsWith("unicastRate") ? "By/s" : "By"'
+        description: ingress bytes
+        emitEach:
+          select: eqptIngrBytes5min/attributes
+          namePrefix: ingr.
+          include: ^(unicast|multicast)
+          exclude: Last$
+          suffixAttribute: aggregation
+        itemAttributes:
+        - name: interface
+          valueFrom: dn
+`
+
+func TestDynamicNamesAndEmitEach(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(emitEachTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/stats": `{"imdata":[{"eqptIngrBytes5min":{"attributes":{"dn":"topology/eth1-1","unit":"By",` +
+				`"unicastAvg":"10","unicastMax":"20","unicastMin":"5","unicastLast":"12","unicastRateAvg":"0.5",` +
+				`"multicastCum":"100","floodAvg":"3","multicastPer":"n/a","repIntvStart":"2023-01-01T00:00:00"}}}]}`,
+		},
+	}
+
+	sink := &batchSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	points := []string{}
+	for _, bundle := range sink.bundles {
+		ms := bundle.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < ms.Len(); i++ {
+			m := ms.At(i)
+			dps := m.Gauge().DataPoints()
+			for j := 0; j < dps.Len(); j++ {
+				points = append(points, fmt.Sprintf("%s %s %s %v %v", m.Name(), m.Unit(), m.Description(), dps.At(j).Attributes().AsRaw(), dps.At(j).DoubleValue()))
+			}
+		}
+	}
+	sort.Strings(points)
+
+	expected := []string{
+		"aci.interface.eth1-1 By ingress unicast bytes of topology/eth1-1 map[] 12",
+		"ingr.multicastCum By ingress bytes map[interface:topology/eth1-1] 100",
+		"ingr.unicast By ingress bytes map[aggregation:avg interface:topology/eth1-1] 10",
+		"ingr.unicast By ingress bytes map[aggregation:max interface:topology/eth1-1] 20",
+		"ingr.unicast By ingress bytes map[aggregation:min interface:topology/eth1-1] 5",
+		"ingr.unicastRate By/s ingress bytes map[aggregation:avg interface:topology/eth1-1] 0.5",
+	}
+	if !reflect.DeepEqual(expected, points) {
+		t.Fatalf("expected:\n%s\nactual:\n%s", strings.Join(expected, "\n"), strings.Join(points, "\n"))
+	}
+}
+
+func TestSplitSuffix(t *testing.T) {
+	each := &EmitEach{SuffixAttribute: "aggregation", Suffixes: map[string]string{"Avg": "avg", "RateAvg": "rate.avg", "Last": "last"}}
+	tests := map[string][]string{
+		"unicastRateAvg": {"unicast", "rate.avg"},
+		"unicastAvg":     {"unicast", "avg"},
+		"unicastLast":    {"unicast", "last"},
+		"unicastMax":     {"unicastMax", ""},
+		"Avg":            {"Avg", ""},
+	}
+	for field, expected := range tests {
+		name, suffix := each.splitSuffix(field)
+		if name != expected[0] || suffix != expected[1] {
+			t.Fatalf("%s expected: %v != actual: %s %s", field, expected, name, suffix)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package jsonscraper
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -44,120 +44,218 @@ func NewEmitter(ctx context.Context, logger *zap.Logger, metricConsumer consumer
 	}
 }
 
-func (e *Emitter) ConsumeMetrics(metricBundle pmetric.Metrics) {
-	e.metricConsumer.ConsumeMetrics(e.ctx, metricBundle)
+func (e *Emitter) ConsumeMetrics(metricBundle pmetric.Metrics) error {
+	return e.metricConsumer.ConsumeMetrics(e.ctx, metricBundle)
 }
 
-func (e *Emitter) ConsumeLogs(logBundle plog.Logs) {
-	e.logConsumer.ConsumeLogs(e.ctx, logBundle)
+func (e *Emitter) ConsumeLogs(logBundle plog.Logs) error {
+	return e.logConsumer.ConsumeLogs(e.ctx, logBundle)
 }
 
 func (e *Emitter) SetSeverityConvertor(convertor func(string) plog.SeverityNumber) {
 	e.severityConvertor = convertor
 }
 
-func (e *Emitter) EmitMetrics(metric *MetricEmit, value float64, scContext *scraperContext, interval int) {
-	metrics := pmetric.NewMetrics()
-	resourceMetrics := metrics.ResourceMetrics().AppendEmpty()
-	resAttrs := resourceMetrics.Resource().Attributes()
+// batchFor - returns batch of the scrape, emits outside of scrape get their own batch flushed right away
+func (e *Emitter) batchFor(scContext *scraperContext) (*emitBatch, func()) {
+	if scContext.batch != nil {
+		return scContext.batch, func() {}
+	}
+	batch := e.newBatch(0)
+	return batch, func() {
+		if err := batch.finish(); err != nil {
+			e.logger.Sugar().Errorf("Cannot emit - %v", err)
+		}
+	}
+}
+
+func (e *Emitter) EmitMetrics(metric *MetricEmit, value float64, timestamp time.Time, scContext *scraperContext, interval int) {
+	e.emitDataPoint(metric, timestamp, scContext, func(scopeMetric pmetric.Metric) dataPoint {
+		var dp pmetric.NumberDataPoint
+		switch metric.Type {
+		case Sum:
+			dp = scopeMetric.Sum().DataPoints().AppendEmpty()
+		default:
+			dp = scopeMetric.Gauge().DataPoints().AppendEmpty()
+		}
+		dp.SetDoubleValue(value)
+		return dp
+	}, interval)
+}
+
+// EmitHistogram - emits one histogram data point, bounds and counts are already validated
+func (e *Emitter) EmitHistogram(metric *MetricEmit, value *histogramValue, timestamp time.Time, scContext *scraperContext, interval int) {
+	e.emitDataPoint(metric, timestamp, scContext, func(scopeMetric pmetric.Metric) dataPoint {
+		dp := scopeMetric.Histogram().DataPoints().AppendEmpty()
+		dp.ExplicitBounds().FromRaw(value.bounds)
+		dp.BucketCounts().FromRaw(value.counts)
+		dp.SetCount(value.count)
+		dp.SetSum(value.sum)
+		return dp
+	}, interval)
+}
+
+// EmitSummary - emits one summary data point
+func (e *Emitter) EmitSummary(metric *MetricEmit, value *summaryValue, timestamp time.Time, scContext *scraperContext, interval int) {
+	e.emitDataPoint(metric, timestamp, scContext, func(scopeMetric pmetric.Metric) dataPoint {
+		dp := scopeMetric.Summary().DataPoints().AppendEmpty()
+		for _, q := range value.quantiles {
+			quantile := dp.QuantileValues().AppendEmpty()
+			quantile.SetQuantile(q.quantile)
+			quantile.SetValue(q.value)
+		}
+		dp.SetCount(value.count)
+		dp.SetSum(value.sum)
+		return dp
+	}, interval)
+}
+
+// dataPoint - common part of number, histogram and summary data points
+type dataPoint interface {
+	Attributes() pcommon.Map
+	SetStartTimestamp(pcommon.Timestamp)
+	SetTimestamp(pcommon.Timestamp)
+}
 
-	rsrcAttrs := scContext.getRsrcAttrs()
-	for n, v := range rsrcAttrs {
-		e.upsertAttribute(&resAttrs, n, v)
+// emitDataPoint - adds data point created by appendDataPoint to the metric in the scrape's batch,
+// zero timestamp means now
+func (e *Emitter) emitDataPoint(metric *MetricEmit, timestamp time.Time, scContext *scraperContext, appendDataPoint func(pmetric.Metric) dataPoint, interval int) {
+	if !metric.Type.IsValid() {
+		e.logger.Sugar().Errorf("Metric %s has unknown type %s", metric.Name, metric.Type)
+		return
 	}
 
-	scopeMetrics := resourceMetrics.ScopeMetrics().AppendEmpty()
-	scopeMetrics.Scope().SetName(scContext.getScope().Name)
-	scopeMetrics.Scope().SetVersion(scContext.getScope().Version)
+	batch, flush := e.batchFor(scContext)
+	defer flush()
+
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+
+	scopeMetric, created := batch.metric(scContext.getRsrcAttrs(), scContext.getScope(), metric.Name, metric.Type)
+	if created {
+		e.setupMetric(scopeMetric, metric)
+	}
 
-	scopeMetric := scopeMetrics.Metrics().AppendEmpty()
+	dp := appendDataPoint(scopeMetric)
+
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	startTime := timestamp.Add(-time.Duration(interval) * time.Second)
+	dp.SetStartTimestamp(pcommon.NewTimestampFromTime(startTime))
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
 
+	itemAttrs := scContext.getItemAttrs()
+	dpAttributes := dp.Attributes()
+	e.upsertAttributes(&dpAttributes, itemAttrs)
+
+	batch.added()
+}
+
+func (e *Emitter) setupMetric(scopeMetric pmetric.Metric, metric *MetricEmit) {
 	scopeMetric.SetName(metric.Name)
 	scopeMetric.SetDescription(metric.Description)
 	scopeMetric.SetUnit(metric.Unit)
 
-	var dp pmetric.NumberDataPoint
-
 	switch metric.Type {
 	case Sum:
 		scopeMetric.SetEmptySum()
-		scopeMetric.Sum().SetIsMonotonic(true)
-		scopeMetric.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
-
-		dp = scopeMetric.Sum().DataPoints().AppendEmpty()
+		scopeMetric.Sum().SetIsMonotonic(metric.isMonotonic())
+		scopeMetric.Sum().SetAggregationTemporality(metric.aggregationTemporality())
 	case Gauge:
 		scopeMetric.SetEmptyGauge()
-		dp = scopeMetric.Gauge().DataPoints().AppendEmpty()
+	case Histogram:
+		scopeMetric.SetEmptyHistogram()
+		scopeMetric.Histogram().SetAggregationTemporality(metric.aggregationTemporality())
+	case Summary:
+		scopeMetric.SetEmptySummary()
 	}
+}
 
-	now := time.Now()
-	startTime := now.Add(-time.Duration(interval))
-	dp.SetStartTimestamp(pcommon.NewTimestampFromTime(startTime))
-	dp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+func (e *Emitter) EmitLogs(log *LogEmit, value *logValue, scContext *scraperContext, interval int) {
+	batch, flush := e.batchFor(scContext)
+	defer flush()
 
-	dp.SetDoubleValue(value)
-	itemAttrs := scContext.getItemAttrs()
-	for n, v := range itemAttrs {
-		dpAttributes := dp.Attributes()
-		e.upsertAttribute(&dpAttributes, n, v)
-	}
-
-	e.ConsumeMetrics(metrics)
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
 
-}
-
-func (e *Emitter) EmitLogs(log *LogEmit, message string, severity string, timestamp string, scContext *scraperContext, interval int) {
-	out := plog.NewLogs()
-	rls := out.ResourceLogs().AppendEmpty()
-	rsrcAttrs := scContext.getRsrcAttrs()
-	for attrName, attrValue := range rsrcAttrs {
-		e.logger.Sugar().Debugf("Setting rsrc attr... %s -> %v", attrName, attrValue)
-		rls.Resource().Attributes().PutStr(attrName, fmt.Sprintf("%v", attrValue))
-	}
-	logSlice := rls.ScopeLogs().AppendEmpty().LogRecords()
-	logRecord := logSlice.AppendEmpty()
+	logRecord := batch.logRecord(scContext.getRsrcAttrs(), scContext.getScope())
 
 	itemAttrs := scContext.getItemAttrs()
-	for attrName, attrValue := range itemAttrs {
-		logRecord.Attributes().PutStr(attrName, fmt.Sprintf("%v", attrValue))
+	logAttrs := logRecord.Attributes()
+	e.upsertAttributes(&logAttrs, itemAttrs)
+	if log.LogType != "" {
+		logAttrs.PutStr(log.logTypeAttribute(), log.LogType)
 	}
-	logRecord.Body().SetStr(message)
+	setAttributeValue(logRecord.Body(), value.body)
 	now := time.Now()
 
-	// timestamp format: 2023-05-30T15:16:26.896+02:00
-	otelTimestamp, err := time.Parse(time.RFC3339, timestamp)
-	if err != nil {
-		e.logger.Sugar().Errorf("Cannot parse date string %s", timestamp)
+	otelTimestamp := value.timestamp
+	if otelTimestamp.IsZero() {
 		otelTimestamp = now
 	}
 
 	logRecord.SetTimestamp(pcommon.NewTimestampFromTime(otelTimestamp))
 	logRecord.SetObservedTimestamp(pcommon.NewTimestampFromTime(now))
 
-	otelSeverity := e.severityConvertor(severity)
+	otelSeverity := value.severityNumber
+	if otelSeverity == plog.SeverityNumberUnspecified {
+		otelSeverity = e.severityConvertor(value.severity)
+	}
 	logRecord.SetSeverityNumber(otelSeverity)
-	logRecord.SetSeverityText(severity)
+	logRecord.SetSeverityText(value.severity)
 
-	e.logger.Sugar().Debugf("Flushing logs... %v", out)
-	e.ConsumeLogs(out)
+	logRecord.SetTraceID(value.traceID)
+	logRecord.SetSpanID(value.spanID)
+
+	batch.added()
 }
 
-func (e *Emitter) upsertAttribute(attributeMap *pcommon.Map, attrName string, attrValue any) {
+// EmitError - emits collection error as a log record with resource and scope of the failed query
+func (e *Emitter) EmitError(collErr *collectionError, scContext *scraperContext) {
+	batch, flush := e.batchFor(scContext)
+	defer flush()
 
-	switch attrValue.(type) {
-	case []string:
-		inSlice := attrValue.([]string)
-		attrSlice := attributeMap.PutEmptySlice(attrName)
-		for _, elem := range inSlice {
-			attrSlice.AppendEmpty().SetStr(elem)
-		}
-	case string:
-		attributeMap.PutStr(attrName, attrValue.(string))
-	default:
-		attributeMap.PutStr(attrName, fmt.Sprintf("%T-toStr-%v", attrValue, attrValue))
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+
+	logRecord := batch.logRecord(scContext.getRsrcAttrs(), scContext.getScope())
+
+	logAttrs := logRecord.Attributes()
+	logAttrs.PutStr(defaultLogTypeAttribute, collectionErrorLogType)
+	logAttrs.PutStr("jsonscraper.query", collErr.query)
+	logAttrs.PutStr("jsonscraper.rule", collErr.rulePath)
+	if collErr.url != "" {
+		logAttrs.PutStr("url.full", collErr.url)
+	}
+	logAttrs.PutStr("error.message", collErr.err.Error())
+	logRecord.Body().SetStr(collErr.Error())
+
+	now := pcommon.NewTimestampFromTime(time.Now())
+	logRecord.SetTimestamp(now)
+	logRecord.SetObservedTimestamp(now)
+	logRecord.SetSeverityNumber(plog.SeverityNumberError)
+	logRecord.SetSeverityText("error")
+
+	batch.added()
+}
+
+// upsertAttributes - sets all attributes in the order of their names, so that the output is stable
+func (e *Emitter) upsertAttributes(attributeMap *pcommon.Map, attrs map[string]any) {
+	names := make([]string, 0, len(attrs))
+	for n := range attrs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		e.upsertAttribute(attributeMap, n, attrs[n])
 	}
 }
 
+func (e *Emitter) upsertAttribute(attributeMap *pcommon.Map, attrName string, attrValue any) {
+	setAttributeValue(attributeMap.PutEmpty(attrName), attrValue)
+}
+
 func DefaultSeverityConvertor(severity string) plog.SeverityNumber {
 	otelSeverity := plog.SeverityNumberInfo
 
@@ -0,0 +1,122 @@
+package jsonscraper
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// ErrorPolicy - what happens when a rule's query or an emit's expression fails
+type ErrorPolicy string
+
+const (
+	OnErrorSkip       ErrorPolicy = "skip"       // the rule or emit is skipped, the rest goes on (default)
+	OnErrorAbort      ErrorPolicy = "abort"      // the scrape of the whole query stops
+	OnErrorUseDefault ErrorPolicy = "useDefault" // the default value is used instead
+)
+
+func (p ErrorPolicy) IsValid() bool {
+	switch p {
+	case "", OnErrorSkip, OnErrorAbort, OnErrorUseDefault:
+		return true
+	}
+	return false
+}
+
+// log.type of log records emitted for collection errors
+const collectionErrorLogType = "collection.error"
+
+// collectionError - failure of one rule or emit, reported in collector's log and,
+// with errorLogs enabled, as a log record in the logs pipeline
+type collectionError struct {
+	query    string
+	rulePath string
+	url      string
+	err      error
+}
+
+func (e *collectionError) Error() string {
+	return fmt.Sprintf("query %s, rule %s, url %s - %v", e.query, e.rulePath, e.url, e.err)
+}
+
+func (e *collectionError) Unwrap() error {
+	return e.err
+}
+
+// handleError - reports the error and applies the policy, returns true when the caller
+// should go on with the default value
+func (g *Scraper) handleError(policy ErrorPolicy, url string, err error, scContext *scraperContext) bool {
+	g.reportError(url, err, scContext)
+
+	switch policy {
+	case OnErrorAbort:
+		if scContext.abort != nil {
+			scContext.abort(fmt.Errorf("query aborted at %s - %v", scContext.rulePath, err))
+		}
+	case OnErrorUseDefault:
+		return true
+	}
+	return false
+}
+
+// reportError - logs the error and emits it as a log record if the receiver asks for it
+func (g *Scraper) reportError(url string, err error, scContext *scraperContext) {
+	collErr := &collectionError{
+		rulePath: scContext.rulePath,
+		url:      url,
+		err:      err,
+	}
+	if scContext.query != nil {
+		collErr.query = scContext.query.Name
+	}
+	g.logger.Sugar().Errorf("Collection error - %v", collErr)
+
+	if !g.config.ErrorLogs || g.emitter.logConsumer == nil {
+		return
+	}
+	g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalLog)
+	scContext.emit(func(emitContext *scraperContext) {
+		g.emitter.EmitError(collErr, emitContext)
+	})
+}
+
+// defaultDocument - document used by rule with useDefault when its query fails, empty object
+// when the rule has no default
+func defaultDocument(defaultValue any) (*jsonquery.Node, error) {
+	if defaultValue == nil {
+		defaultValue = map[string]any{}
+	}
+	return toNode(yamlToJSON(defaultValue))
+}
+
+// defaultNumber - default value of metric emit with useDefault
+func defaultNumber(defaultValue any) (float64, error) {
+	if defaultValue == nil {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(fmt.Sprintf("%v", defaultValue), 64)
+	if err != nil {
+		return 0, fmt.Errorf("default %v is not number", defaultValue)
+	}
+	return value, nil
+}
+
+// yamlToJSON - converts maps parsed by yaml.v2 to maps with string keys
+func yamlToJSON(value any) any {
+	switch v := value.(type) {
+	case map[any]any:
+		converted := map[string]any{}
+		for key, item := range v {
+			converted[fmt.Sprintf("%v", key)] = yamlToJSON(item)
+		}
+		return converted
+	case []any:
+		converted := make([]any, len(v))
+		for i, item := range v {
+			converted[i] = yamlToJSON(item)
+		}
+		return converted
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package jsonscraper
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+const errorsTestQueries = `
+queries:
+- name: Skip
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /nodes
+    emitMetric:
+    - name: broken
+      type: gauge
+      valueFrom: =double(jqs("missing"))
+    - name: defaulted
+      type: gauge
+      valueFrom: =double(jqs("missing"))
+      onError: useDefault
+      default: -1
+    - name: count
+      type: gauge
+      valueFrom: =double(jqs("count"))
+    children:
+    - query: /missing
+      onError: useDefault
+      default:
+        count: "7"
+      emitMetric:
+      - name: fromDefault
+        type: gauge
+        valueFrom: =double(jqs("count"))
+- name: Abort
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /nodes
+    children:
+    - query: /missing
+      onError: abort
+    - emitMetric:
+      - name: afterAbort
+        type: gauge
+        valueFrom: =double(jqs("count"))
+`
+
+func runErrorsTestScrape(t *testing.T, queries string, metricConsumer consumer.Metrics, logConsumer consumer.Logs, errorLogs bool) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(queries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	config.ErrorLogs = errorLogs
+	client := &fakeClient{
+		responses: map[string]string{
+			"/nodes": `{"count":"3"}`,
+		},
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, logConsumer)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+}
+
+func TestOnErrorPolicies(t *testing.T) {
+	values := map[string]float64{}
+	metricConsumer, err := consumer.NewMetrics(func(_ context.Context, metrics pmetric.Metrics) error {
+		rms := metrics.ResourceMetrics()
+		for i := 0; i < rms.Len(); i++ {
+			sms := rms.At(i).ScopeMetrics()
+			for j := 0; j < sms.Len(); j++ {
+				ms := sms.At(j).Metrics()
+				for k := 0; k < ms.Len(); k++ {
+					values[ms.At(k).Name()] = ms.At(k).Gauge().DataPoints().At(0).DoubleValue()
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	runErrorsTestScrape(t, errorsTestQueries, metricConsumer, nil, false)
+
+	expected := map[string]float64{
+		"defaulted":   -1, // useDefault
+		"count":       3,  // emits after a skipped one still run
+		"fromDefault": 7,  // rule's default document
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("expected: %v != actual: %v", expected, values)
+	}
+	for name, value := range expected {
+		if actual, ok := values[name]; !ok || actual != value {
+			t.Fatalf("%s expected: %v != actual: %v", name, value, actual)
+		}
+	}
+}
+
+func TestErrorLogs(t *testing.T) {
+	records := []plog.LogRecord{}
+	logConsumer, err := consumer.NewLogs(func(_ context.Context, logs plog.Logs) error {
+		rls := logs.ResourceLogs()
+		for i := 0; i < rls.Len(); i++ {
+			lrs := rls.At(i).ScopeLogs().At(0).LogRecords()
+			for j := 0; j < lrs.Len(); j++ {
+				records = append(records, lrs.At(j))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	runErrorsTestScrape(t, errorsTestQueries, nil, logConsumer, true)
+
+	// emits of metrics are not evaluated by log receiver, only the failed queries are reported
+	found := []string{}
+	for _, record := range records {
+		attrs := record.Attributes().AsRaw()
+		if attrs["log.type"] != collectionErrorLogType || record.SeverityNumber() != plog.SeverityNumberError {
+			t.Fatalf("unexpected record %v", attrs)
+		}
+		if !strings.Contains(attrs["error.message"].(string), "no response for /missing") {
+			t.Fatalf("unexpected error message %v", attrs["error.message"])
+		}
+		found = append(found, attrs["jsonscraper.query"].(string)+" "+attrs["jsonscraper.rule"].(string)+" "+attrs["url.full"].(string))
+	}
+	sort.Strings(found)
+	expected := []string{
+		"Abort rules/children[0] /missing",
+		"Skip rules/children[0] /missing",
+	}
+	if strings.Join(found, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("expected: %v != actual: %v", expected, found)
+	}
+}
+
+func TestInvalidErrorPolicy(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(`
+queries:
+- name: Invalid
+  rules:
+    query: /nodes
+    emitMetric:
+    - name: broken
+      onError: ignore
+`))
+	if err == nil || !strings.Contains(err.Error(), "invalid onError ignore") {
+		t.Fatalf("expected invalid onError error, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package jsonscraper
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+)
+
+const filtersTestQueries = `
+queries:
+- name: Nodes
+  resource:
+    name: ACI
+    attributes:
+    - name: aci.fabric.name
+      value: Demo-ACI
+  scope:
+    name: test
+  rules:
+    query: /nodes
+    select: imdata/*
+    forEach:
+      queryParameters:
+      - name: node
+        valueFrom: name
+      - name: role
+        valueFrom: role
+      filters:
+      - name: Leaves only
+        is: =params["role"] == "leaf"
+      itemAttributes:
+      - name: node
+        valueFrom: =params["node"]
+      query: /health/${node}
+      emitMetric:
+      - name: node.health
+        type: gauge
+        valueFrom: health
+        filters:
+        - name: Demo fabric and known node
+          is: =resAttr["aci.fabric.name"] == "Demo-ACI" && attr["node"] != "" && params["node"] == attr["node"]
+`
+
+func TestFiltersWithContext(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(filtersTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/nodes":        `{"imdata":[{"name":"leaf1","role":"leaf"},{"name":"spine1","role":"spine"},{"name":"leaf2","role":"leaf"}]}`,
+			"/health/leaf1": `{"health":"90"}`,
+			"/health/leaf2": `{"health":"95"}`,
+		},
+	}
+
+	sink := &metricSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	// spine is dropped before its sub-query
+	if strings.Join(client.requests, ",") != "/nodes,/health/leaf1,/health/leaf2" {
+		t.Fatalf("unexpected requests %v", client.requests)
+	}
+	if len(sink.points) != 2 {
+		t.Fatalf("expected: 2 data points != actual: %v", sink.points)
+	}
+}
@@ -0,0 +1,255 @@
+package jsonscraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const redacted = "REDACTED"
+
+// fixture - one recorded request and response, stored as NNNN-method-path.json
+type fixture struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Payload  *string         `json:"payload,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"` // JSON responses are kept readable
+	Text     *string         `json:"text,omitempty"`     // non JSON responses
+	Headers  http.Header     `json:"headers,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Time     time.Time       `json:"time"` // when the response was received
+}
+
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)("(?:pwd|password|passwd|token|secret|apiKey|api_key|sessionId|refreshToken|privateKey)"\s*:\s*")(?:[^"\\]|\\.)*(")`),
+	regexp.MustCompile(`(?i)([?&](?:pwd|password|token|secret|apiKey|api_key|signature)=)[^&]*()`),
+}
+
+var secretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Apic-Challenge"}
+
+var fixtureNameRegexp = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+
+// RecordingClient - wraps ScraperClient and writes every request and response
+// into fixture directory, secrets are redacted
+type RecordingClient struct {
+	logger  *zap.Logger
+	client  ScraperClient
+	dir     string
+	secrets []string
+	mutex   sync.Mutex
+	seq     int
+}
+
+// NewRecordingClient - secrets are literal values, e.g. passwords, replaced in everything written
+func NewRecordingClient(logger *zap.Logger, client ScraperClient, dir string, secrets ...string) (*RecordingClient, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create fixture directory %s - %v", dir, err)
+	}
+	nonEmpty := []string{}
+	for _, secret := range secrets {
+		if secret != "" {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+	return &RecordingClient{logger: logger, client: client, dir: dir, secrets: nonEmpty}, nil
+}
+
+func (c *RecordingClient) Login(ctx context.Context) error {
+	return c.client.Login(ctx)
+}
+
+func (c *RecordingClient) Logout(ctx context.Context) error {
+	return c.client.Logout(ctx)
+}
+
+func (c *RecordingClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	response, err := c.client.DoRequest(ctx, method, url, payload)
+	c.record(method, url, payload, response, nil, err)
+	return response, err
+}
+
+// DoRequestWithHeaders - implement interface ScraperHeaderClient when the wrapped client does
+func (c *RecordingClient) DoRequestWithHeaders(ctx context.Context, method string, url string, payload *string) (string, http.Header, error) {
+	headerClient, ok := c.client.(ScraperHeaderClient)
+	if !ok {
+		return "", nil, fmt.Errorf("recorded client %T does not provide response headers", c.client)
+	}
+	response, headers, err := headerClient.DoRequestWithHeaders(ctx, method, url, payload)
+	c.record(method, url, payload, response, headers, err)
+	return response, headers, err
+}
+
+func (c *RecordingClient) record(method string, url string, payload *string, response string, headers http.Header, err error) {
+	f := fixture{
+		Method: method,
+		URL:    c.redact(url),
+		Time:   time.Now().UTC().Truncate(time.Second),
+	}
+	if payload != nil {
+		redactedPayload := c.redact(*payload)
+		f.Payload = &redactedPayload
+	}
+	if err != nil {
+		f.Error = c.redact(err.Error())
+	} else {
+		response = c.redact(response)
+		if json.Valid([]byte(response)) && strings.TrimSpace(response) != "" {
+			f.Response = json.RawMessage(response)
+		} else {
+			f.Text = &response
+		}
+	}
+	if len(headers) > 0 {
+		f.Headers = headers.Clone()
+		for _, header := range secretHeaders {
+			if f.Headers.Get(header) != "" {
+				f.Headers.Set(header, redacted)
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	c.seq++
+	seq := c.seq
+	c.mutex.Unlock()
+
+	path, _, _ := strings.Cut(url, "?")
+	name := strings.Trim(fixtureNameRegexp.ReplaceAllString(method+"-"+path, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	fixtureBytes := bytes.Buffer{}
+	encoder := json.NewEncoder(&fixtureBytes)
+	encoder.SetEscapeHTML(false) // keep & in URLs readable
+	encoder.SetIndent("", "  ")
+	marshalErr := encoder.Encode(f)
+	if marshalErr == nil {
+		marshalErr = os.WriteFile(filepath.Join(c.dir, fmt.Sprintf("%04d-%s.json", seq, name)), fixtureBytes.Bytes(), 0o644)
+	}
+	if marshalErr != nil {
+		c.logger.Sugar().Errorf("Cannot record fixture of %s %s - %v", method, c.redact(url), marshalErr)
+	}
+}
+
+func (c *RecordingClient) redact(value string) string {
+	for _, secret := range c.secrets {
+		value = strings.ReplaceAll(value, secret, redacted)
+	}
+	for _, pattern := range secretPatterns {
+		value = pattern.ReplaceAllString(value, "${1}"+redacted+"${2}")
+	}
+	return value
+}
+
+// ReplayClient - serves fixtures written by RecordingClient. Requests are matched on method and URL,
+// repeated requests get the fixtures in the recorded order. Bodies are not compared as they
+// often contain time ranges.
+type ReplayClient struct {
+	mutex      sync.Mutex
+	fixtures   map[string][]*fixture
+	missed     map[string]bool
+	recordedAt time.Time
+}
+
+func NewReplayClient(dir string) (*ReplayClient, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	c := &ReplayClient{fixtures: map[string][]*fixture{}, missed: map[string]bool{}}
+	for _, file := range files {
+		fixtureBytes, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read fixture %s - %v", file, err)
+		}
+		f := &fixture{}
+		if err = json.Unmarshal(fixtureBytes, f); err != nil {
+			return nil, fmt.Errorf("cannot parse fixture %s - %v", file, err)
+		}
+		key := f.Method + " " + f.URL
+		c.fixtures[key] = append(c.fixtures[key], f)
+		if !f.Time.IsZero() && (c.recordedAt.IsZero() || f.Time.Before(c.recordedAt)) {
+			c.recordedAt = f.Time
+		}
+	}
+	return c, nil
+}
+
+func (c *ReplayClient) Login(ctx context.Context) error {
+	return nil
+}
+
+func (c *ReplayClient) Logout(ctx context.Context) error {
+	return nil
+}
+
+func (c *ReplayClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	response, _, err := c.DoRequestWithHeaders(ctx, method, url, payload)
+	return response, err
+}
+
+func (c *ReplayClient) DoRequestWithHeaders(ctx context.Context, method string, url string, payload *string) (string, http.Header, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := method + " " + url
+	queue := c.fixtures[key]
+	if len(queue) == 0 {
+		c.missed[key] = true
+		return "", nil, fmt.Errorf("no fixture left for %s", key)
+	}
+	f := queue[0]
+	c.fixtures[key] = queue[1:]
+
+	if f.Error != "" {
+		return "", nil, fmt.Errorf("%s", f.Error)
+	}
+	if f.Text != nil {
+		return *f.Text, f.Headers, nil
+	}
+	return string(f.Response), f.Headers, nil
+}
+
+// RecordedAt - time of the first recorded response, zero when the fixtures carry no time
+func (c *ReplayClient) RecordedAt() time.Time {
+	return c.recordedAt
+}
+
+// Unused - requests with fixtures not served yet
+func (c *ReplayClient) Unused() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	unused := []string{}
+	for key, queue := range c.fixtures {
+		for range queue {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// Missed - requests which found no fixture left
+func (c *ReplayClient) Missed() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	missed := []string{}
+	for key := range c.missed {
+		missed = append(missed, key)
+	}
+	sort.Strings(missed)
+	return missed
+}
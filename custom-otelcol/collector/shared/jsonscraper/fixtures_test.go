@@ -0,0 +1,74 @@
+package jsonscraper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	client := &fakeClient{
+		responses: map[string]string{
+			"/api/aaaLogin.json?token=abc": `{"imdata":[{"aaaLogin":{"attributes":{"token":"abc","userName":"admin"}}}]}`,
+			"/api/class/fabricNode.json":   `{"imdata":[{"fabricNode":{"attributes":{"name":"leaf1"}}}]}`,
+			"/metrics":                     "up 1\n",
+		},
+	}
+	recorder, err := NewRecordingClient(zap.NewNop(), client, dir, "s3cret")
+	if err != nil {
+		t.Fatalf("Cannot create recorder - %v", err)
+	}
+
+	ctx := context.Background()
+	started := time.Now().Truncate(time.Second)
+	payload := `{"aaaUser":{"attributes":{"name":"admin","pwd":"s3cret"}}}`
+	recorder.DoRequest(ctx, "POST", "/api/aaaLogin.json?token=abc", &payload)
+	recorder.DoRequest(ctx, "GET", "/api/class/fabricNode.json", nil)
+	recorder.DoRequest(ctx, "GET", "/api/class/fabricNode.json", nil)
+	recorder.DoRequest(ctx, "GET", "/metrics", nil)
+	recorder.DoRequest(ctx, "GET", "/missing", nil)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 5 || filepath.Base(files[1]) != "0002-GET_api_class_fabricNode.json.json" {
+		t.Fatalf("unexpected fixture files %v", files)
+	}
+	for _, file := range files {
+		content, _ := os.ReadFile(file)
+		if strings.Contains(string(content), "s3cret") || strings.Contains(string(content), "abc") {
+			t.Fatalf("secret not redacted in %s:\n%s", file, content)
+		}
+	}
+
+	replay, err := NewReplayClient(dir)
+	if err != nil {
+		t.Fatalf("Cannot create replay client - %v", err)
+	}
+	if recordedAt := replay.RecordedAt(); recordedAt.Before(started) || recordedAt.After(time.Now()) {
+		t.Fatalf("expected recording time between %v and now, got %v", started, recordedAt)
+	}
+	doc, err := newTestScraper(t, replay).getDataFromService(ctx, "", "GET", "/api/class/fabricNode.json", nil)
+	if err != nil || doc == nil {
+		t.Fatalf("Cannot replay fixture - %v", err)
+	}
+	if _, err = replay.DoRequest(ctx, "GET", "/api/class/fabricNode.json", nil); err != nil {
+		t.Fatalf("Second recorded response expected - %v", err)
+	}
+	if _, err = replay.DoRequest(ctx, "GET", "/api/class/fabricNode.json", nil); err == nil {
+		t.Fatalf("expected error after all fixtures were served")
+	}
+	if text, _ := replay.DoRequest(ctx, "GET", "/metrics", nil); text != "up 1\n" {
+		t.Fatalf("expected: text response != actual: %q", text)
+	}
+	if _, err = replay.DoRequest(ctx, "GET", "/missing", nil); err == nil || !strings.Contains(err.Error(), "no response for /missing") {
+		t.Fatalf("expected recorded error, got %v", err)
+	}
+	if unused := replay.Unused(); len(unused) != 1 || unused[0] != "POST /api/aaaLogin.json?token=REDACTED" {
+		t.Fatalf("unexpected unused fixtures %v", unused)
+	}
+}
@@ -0,0 +1,415 @@
+package jsonscraper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Query files can define reusable parts which are expanded when the file is loaded:
+//
+//	include:             files with more queries, templates and fragments, relative to the including file
+//	- common.yaml
+//	fragments:           named YAML values, e.g. resource, scope, or a list of filters
+//	  <name>: <value>
+//	templates:           named queries with parameters
+//	  <name>:
+//	    params:          parameters with their defaults, parameters without default must be set
+//	      <param>: <default>
+//	    query: <query>
+//
+// A mapping with use: is replaced by the template or fragment, with: sets the parameters referenced
+// as {{param}} in its body and the other keys of the mapping override keys of the expanded mapping.
+// A fragment which is a list is spliced into the list where it is used. Scalar which is just {{param}}
+// takes the parameter value of any type, e.g. a list.
+
+// uses nested deeper are most likely a cycle
+const maxUseDepth = 32
+
+var paramRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// definition - template or fragment
+type definition struct {
+	name     string
+	body     *yaml.Node
+	params   map[string]*yaml.Node // declared parameters with defaults, nil for fragments
+	template bool
+}
+
+// expander - loads query file with its includes and expands uses of templates and fragments
+type expander struct {
+	definitions map[string]*definition
+	origins     map[*yaml.Node]string // file of each node, for errors
+	loaded      map[string]bool       // each file is loaded once, so includes can't cycle
+	includes    []string              // included files, for watching them
+	using       []string              // templates and fragments being expanded
+}
+
+func newExpander() *expander {
+	return &expander{
+		definitions: map[string]*definition{},
+		origins:     map[*yaml.Node]string{},
+		loaded:      map[string]bool{},
+	}
+}
+
+func (e *expander) errorf(node *yaml.Node, format string, args ...any) error {
+	return fmt.Errorf("%s:%d:%d: %s", e.origins[node], node.Line, node.Column, fmt.Sprintf(format, args...))
+}
+
+// expandQueryFile - returns the root mapping of the file with included queries added and all uses
+// expanded, nil for empty file
+func (e *expander) expandQueryFile(source string, content []byte) (*yaml.Node, error) {
+	root, err := e.load(source, content)
+	if err != nil || root == nil {
+		return root, err
+	}
+	if err = e.expand(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// load - parses the file and its includes, collects their templates and fragments
+func (e *expander) load(source string, content []byte) (*yaml.Node, error) {
+	e.loaded[filepath.Clean(source)] = true
+
+	doc := &yaml.Node{}
+	if err := yaml.NewDecoder(bytes.NewReader(content)).Decode(doc); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	e.setOrigin(doc, source)
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, e.errorf(root, "expected mapping with queries")
+	}
+
+	included := []*yaml.Node{}
+	if includes := mappingValue(root, "include"); includes != nil {
+		if includes.Kind != yaml.SequenceNode {
+			return nil, e.errorf(includes, "expected list of files to include")
+		}
+		for _, include := range includes.Content {
+			file := include.Value
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(filepath.Dir(source), file)
+			}
+			if e.loaded[filepath.Clean(file)] {
+				continue
+			}
+			includedContent, err := os.ReadFile(file)
+			if err != nil {
+				return nil, e.errorf(include, "cannot read included file - %v", err)
+			}
+			e.includes = append(e.includes, file)
+			includedRoot, err := e.load(file, includedContent)
+			if err != nil {
+				return nil, err
+			}
+			if includedRoot == nil {
+				continue
+			}
+			if queries := mappingValue(includedRoot, "queries"); queries != nil {
+				for _, query := range queries.Content {
+					// copy resolves aliases to anchors of the included file
+					copied, _ := e.substitute(query, nil)
+					included = append(included, copied)
+				}
+			}
+		}
+	}
+
+	if err := e.define(mappingValue(root, "fragments"), false); err != nil {
+		return nil, err
+	}
+	if err := e.define(mappingValue(root, "templates"), true); err != nil {
+		return nil, err
+	}
+
+	// queries of the included files go first, then queries of the file itself
+	rootContent := []*yaml.Node{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		switch root.Content[i].Value {
+		case "include", "fragments", "templates":
+			continue
+		case "queries":
+			queries := root.Content[i+1]
+			if queries.Tag == "!!null" {
+				queries.Kind, queries.Tag = yaml.SequenceNode, "!!seq"
+			}
+			queries.Content = append(included, queries.Content...)
+			included = nil
+		}
+		rootContent = append(rootContent, root.Content[i], root.Content[i+1])
+	}
+	if len(included) > 0 {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "queries"}
+		rootContent = append(rootContent, key, &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: included})
+	}
+	root.Content = rootContent
+	return root, nil
+}
+
+func (e *expander) setOrigin(node *yaml.Node, source string) {
+	e.origins[node] = source
+	for _, child := range node.Content {
+		e.setOrigin(child, source)
+	}
+}
+
+// define - registers templates or fragments, names are unique across all loaded files
+func (e *expander) define(definitions *yaml.Node, template bool) error {
+	if definitions == nil {
+		return nil
+	}
+	if definitions.Kind != yaml.MappingNode {
+		return e.errorf(definitions, "expected mapping of names to definitions")
+	}
+	for i := 0; i+1 < len(definitions.Content); i += 2 {
+		name, body := definitions.Content[i], definitions.Content[i+1]
+		if _, ok := e.definitions[name.Value]; ok {
+			return e.errorf(name, "template or fragment %s is already defined", name.Value)
+		}
+		def := &definition{
+			name:     name.Value,
+			body:     body,
+			template: template,
+		}
+		if template {
+			if def.body = mappingValue(body, "query"); def.body == nil {
+				return e.errorf(name, "template %s has no query", name.Value)
+			}
+			def.params = map[string]*yaml.Node{}
+			if params := mappingValue(body, "params"); params != nil {
+				if params.Kind != yaml.MappingNode {
+					return e.errorf(params, "expected mapping of parameters to their defaults")
+				}
+				for j := 0; j+1 < len(params.Content); j += 2 {
+					def.params[params.Content[j].Value] = params.Content[j+1]
+				}
+			}
+		}
+		e.definitions[name.Value] = def
+	}
+	return nil
+}
+
+func isUse(node *yaml.Node) bool {
+	return node.Kind == yaml.MappingNode && mappingValue(node, "use") != nil
+}
+
+// expand - replaces uses within the node
+func (e *expander) expand(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		items := []*yaml.Node{}
+		for _, item := range node.Content {
+			item = e.resolveAlias(item)
+			if !isUse(item) {
+				if err := e.expand(item); err != nil {
+					return err
+				}
+				items = append(items, item)
+				continue
+			}
+			expanded, err := e.use(item)
+			if err != nil {
+				return err
+			}
+			if expanded.Kind == yaml.SequenceNode {
+				items = append(items, expanded.Content...)
+			} else {
+				items = append(items, expanded)
+			}
+		}
+		node.Content = items
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			node.Content[i] = e.resolveAlias(node.Content[i])
+			if !isUse(node.Content[i]) {
+				if err := e.expand(node.Content[i]); err != nil {
+					return err
+				}
+				continue
+			}
+			expanded, err := e.use(node.Content[i])
+			if err != nil {
+				return err
+			}
+			node.Content[i] = expanded
+		}
+	}
+	return nil
+}
+
+// resolveAlias - anchors may be in removed templates and fragments, so aliases are replaced by copies
+func (e *expander) resolveAlias(node *yaml.Node) *yaml.Node {
+	if node.Kind != yaml.AliasNode {
+		return node
+	}
+	copied, _ := e.substitute(node, nil)
+	return copied
+}
+
+// use - returns expanded copy of the template or fragment
+func (e *expander) use(node *yaml.Node) (*yaml.Node, error) {
+	nameNode := mappingValue(node, "use")
+	name := nameNode.Value
+	def, ok := e.definitions[name]
+	if !ok {
+		return nil, e.errorf(nameNode, "unknown template or fragment %s", name)
+	}
+	for _, using := range e.using {
+		if using == name {
+			return nil, e.errorf(nameNode, "%s uses itself through %s", name, strings.Join(e.using, ", "))
+		}
+	}
+	if len(e.using) >= maxUseDepth {
+		return nil, e.errorf(nameNode, "uses nested deeper than %d", maxUseDepth)
+	}
+	e.using = append(e.using, name)
+	defer func() {
+		e.using = e.using[:len(e.using)-1]
+	}()
+
+	params, err := e.params(def, node)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := e.substitute(def.body, params)
+	if err != nil {
+		return nil, err
+	}
+	if isUse(expanded) {
+		expanded, err = e.use(expanded)
+	} else {
+		err = e.expand(expanded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// keys next to use: override keys of the expanded mapping
+	overrides := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i].Value; key != "use" && key != "with" {
+			overrides.Content = append(overrides.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	if len(overrides.Content) == 0 {
+		return expanded, nil
+	}
+	if expanded.Kind != yaml.MappingNode {
+		return nil, e.errorf(node, "%s is not a mapping, other keys cannot be set next to its use", name)
+	}
+	if err = e.expand(overrides); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(overrides.Content); i += 2 {
+		key := overrides.Content[i].Value
+		replaced := false
+		for j := 0; j+1 < len(expanded.Content); j += 2 {
+			if expanded.Content[j].Value == key {
+				expanded.Content[j+1] = overrides.Content[i+1]
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			expanded.Content = append(expanded.Content, overrides.Content[i], overrides.Content[i+1])
+		}
+	}
+	return expanded, nil
+}
+
+// params - values of parameters of the use, defaults of the template overridden by with:
+func (e *expander) params(def *definition, node *yaml.Node) (map[string]*yaml.Node, error) {
+	params := map[string]*yaml.Node{}
+	for param, value := range def.params {
+		if value.Tag != "!!null" {
+			params[param] = value
+		}
+	}
+	with := mappingValue(node, "with")
+	if with != nil {
+		if with.Kind != yaml.MappingNode {
+			return nil, e.errorf(with, "expected mapping of parameters")
+		}
+		for i := 0; i+1 < len(with.Content); i += 2 {
+			param := with.Content[i]
+			if _, declared := def.params[param.Value]; def.template && !declared {
+				return nil, e.errorf(param, "template %s has no parameter %s", def.name, param.Value)
+			}
+			params[param.Value] = with.Content[i+1]
+		}
+	}
+	for param := range def.params {
+		if _, ok := params[param]; !ok {
+			return nil, e.errorf(node, "parameter %s of template %s is not set", param, def.name)
+		}
+	}
+	return params, nil
+}
+
+// substitute - deep copy of the node with {{param}} replaced by parameter values
+func (e *expander) substitute(node *yaml.Node, params map[string]*yaml.Node) (*yaml.Node, error) {
+	if node.Kind == yaml.AliasNode {
+		return e.substitute(node.Alias, params)
+	}
+	copied := *node
+	copied.Anchor = ""
+	copied.Content = nil
+	e.origins[&copied] = e.origins[node]
+
+	if node.Kind == yaml.ScalarNode && params != nil {
+		matches := paramRegexp.FindAllStringSubmatchIndex(node.Value, -1)
+		if len(matches) == 0 {
+			return &copied, nil
+		}
+		// whole scalar is one parameter, value of any kind
+		if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(node.Value) {
+			param := node.Value[matches[0][2]:matches[0][3]]
+			value, ok := params[param]
+			if !ok {
+				return nil, e.errorf(node, "parameter %s is not set", param)
+			}
+			// values of the use are copied as they are
+			return e.substitute(value, nil)
+		}
+		var err error
+		copied.Value = paramRegexp.ReplaceAllStringFunc(node.Value, func(placeholder string) string {
+			param := paramRegexp.FindStringSubmatch(placeholder)[1]
+			value, ok := params[param]
+			switch {
+			case !ok:
+				err = e.errorf(node, "parameter %s is not set", param)
+			case value.Kind != yaml.ScalarNode:
+				err = e.errorf(node, "parameter %s is not a scalar, it can't be part of a string", param)
+			default:
+				return value.Value
+			}
+			return placeholder
+		})
+		// the tag is resolved again from the new value
+		copied.Tag = ""
+		return &copied, err
+	}
+
+	for _, child := range node.Content {
+		substituted, err := e.substitute(child, params)
+		if err != nil {
+			return nil, err
+		}
+		copied.Content = append(copied.Content, substituted)
+	}
+	return &copied, nil
+}
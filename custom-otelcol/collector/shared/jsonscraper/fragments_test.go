@@ -0,0 +1,239 @@
+package jsonscraper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const commonQueries = `
+fragments:
+  aciResource:
+    name: ACI
+    attributes:
+    - name: aci.fabric.name
+      value: "{{fabric}}"
+  aciScope:
+    name: aci-scrapper
+    version: 1.0.0
+  recentLogs:
+  - name: Within Last Collection Interval
+    is: =(jqs("attributes/created").toUnixMillis() + 2 * 60 * 1000) > now().toUnixMillis()
+  - name: Remove duplicates
+    is: =notSeen([jqs("attributes/created"),jqs("attributes/dn"),jqs("attributes/id")])
+queries:
+- name: Included
+  resource:
+    use: aciResource
+    with:
+      fabric: Included
+  scope:
+    use: aciScope
+  rules:
+    query: /included
+`
+
+const templateQueries = `
+include:
+- common.yaml
+templates:
+  records:
+    params:
+      class:
+      kind: fault
+      pageSize: 15
+    query:
+      name: "{{class}} as logs"
+      resource:
+        use: aciResource
+        with:
+          fabric: Demo-ACI
+      scope:
+        use: aciScope
+      rules:
+        query: /api/node/class/{{class}}.json?order-by={{class}}.created|desc
+        paginate:
+          strategy: aci
+          pageSize: "{{pageSize}}"
+        select: imdata//{{class}}
+        forEach:
+          emitLogs:
+          - filters:
+            - use: recentLogs
+            - name: Severity
+              is: =jqs("attributes/severity") != "cleared"
+            logType: "{{kind}}"
+            messageFrom: attributes/descr
+queries:
+- use: records
+  with:
+    class: faultRecord
+- use: records
+  with:
+    class: eventRecord
+    kind: event
+    pageSize: 60
+  name: Events
+  interval: 300
+`
+
+func writeQueryFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Cannot write %s - %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestTemplatesAndFragments(t *testing.T) {
+	dir := writeQueryFiles(t, map[string]string{
+		"common.yaml":  commonQueries,
+		"queries.yaml": templateQueries,
+	})
+	source := filepath.Join(dir, "queries.yaml")
+	rules, _ := os.ReadFile(source)
+
+	config := NewScraperConfig()
+	if err := config.AddQueryRulesFrom(source, rules); err != nil {
+		t.Fatalf("Cannot load queries - %v", err)
+	}
+	if len(config.Queries) != 3 {
+		t.Fatalf("expected: 3 queries != actual: %d", len(config.Queries))
+	}
+	if includes := config.Includes(); len(includes) != 1 || includes[0] != filepath.Join(dir, "common.yaml") {
+		t.Fatalf("unexpected includes %v", includes)
+	}
+
+	included := config.Queries[0]
+	if included.Name != "Included" || included.Resource.Attributes[0].Value != "Included" || included.Scope.Name != "aci-scrapper" {
+		t.Fatalf("unexpected included query %+v", included)
+	}
+
+	faults := config.Queries[1]
+	if faults.Name != "faultRecord as logs" || faults.Rules.Query != "/api/node/class/faultRecord.json?order-by=faultRecord.created|desc" {
+		t.Fatalf("unexpected faults query %+v", faults)
+	}
+	if faults.Resource.Attributes[0].Value != "Demo-ACI" || faults.Rules.Paginate.PageSize != 15 {
+		t.Fatalf("unexpected faults resource or paginate %+v %+v", faults.Resource, faults.Rules.Paginate)
+	}
+	emit := faults.Rules.ForEach.EmitLogs[0]
+	if emit.LogType != "fault" || len(emit.Filters) != 3 || emit.Filters[0].Name != "Within Last Collection Interval" || emit.Filters[2].Name != "Severity" {
+		t.Fatalf("unexpected faults emit %+v", emit)
+	}
+
+	events := config.Queries[2]
+	if events.Name != "Events" || events.Interval != 300 || events.Rules.Paginate.PageSize != 60 || events.Rules.ForEach.EmitLogs[0].LogType != "event" {
+		t.Fatalf("unexpected events query %+v", events)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		queries  string
+		expected string
+	}{
+		{
+			name: "unknown",
+			queries: `
+queries:
+- use: missing
+`,
+			expected: "queries.yaml:3:8: unknown template or fragment missing",
+		},
+		{
+			name: "required parameter",
+			queries: `
+templates:
+  faults:
+    params:
+      class:
+    query:
+      name: "{{class}}"
+queries:
+- use: faults
+`,
+			expected: "queries.yaml:9:3: parameter class of template faults is not set",
+		},
+		{
+			name: "undeclared parameter",
+			queries: `
+templates:
+  faults:
+    query:
+      name: faults
+queries:
+- use: faults
+  with:
+    class: faultRecord
+`,
+			expected: "queries.yaml:9:5: template faults has no parameter class",
+		},
+		{
+			name: "cycle",
+			queries: `
+fragments:
+  a:
+    use: b
+  b:
+    use: a
+queries:
+- resource:
+    use: a
+`,
+			expected: "uses itself",
+		},
+		{
+			name: "included file",
+			queries: `
+include:
+- broken.yaml
+`,
+			expected: "broken.yaml:5:5: unknown field emitMetrics in Rule",
+		},
+	}
+
+	dir := writeQueryFiles(t, map[string]string{
+		"broken.yaml": `
+queries:
+- name: Broken
+  rules:
+    emitMetrics: []
+`,
+	})
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			config := NewScraperConfig()
+			err := config.AddQueryRulesFrom(filepath.Join(dir, "queries.yaml"), []byte(test.queries))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Fatalf("expected: %s != actual: %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestInlineQueries(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddInlineQueries("inlineQueries", map[string]any{
+		"fragments": map[string]any{
+			"resource": map[string]any{"name": "ACI"},
+		},
+		"queries": []any{
+			map[string]any{
+				"name":     "Inline",
+				"resource": map[string]any{"use": "resource"},
+				"rules":    map[string]any{"query": "/inline"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Cannot load inline queries - %v", err)
+	}
+	if len(config.Queries) != 1 || config.Queries[0].Resource.Name != "ACI" || config.Queries[0].Rules.Query != "/inline" {
+		t.Fatalf("unexpected queries %+v", config.Queries)
+	}
+}
@@ -0,0 +1,157 @@
+package jsonscraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// GoldenQuery - query file replayed against its fixtures, signal is metrics or logs
+type GoldenQuery struct {
+	File   string
+	Signal string
+}
+
+// ReplayQueryFile - scrapes the query file once against fixtures recorded by RecordingClient and
+// returns emitted data as indented JSON, now() returns the time of recording and timestamps
+// taken from the clock are zeroed
+func ReplayQueryFile(queryFile string, fixtureDir string, signal string) ([]byte, error) {
+	queries, err := os.ReadFile(queryFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read query file - %v", err)
+	}
+	config := NewScraperConfig()
+	if err = config.AddQueryRules(queries); err != nil {
+		return nil, fmt.Errorf("cannot parse query file - %v", err)
+	}
+	client, err := NewReplayClient(fixtureDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load fixtures - %v", err)
+	}
+
+	metrics := pmetric.NewMetrics()
+	logs := plog.NewLogs()
+	metricConsumer, _ := consumer.NewMetrics(func(_ context.Context, md pmetric.Metrics) error {
+		md.ResourceMetrics().MoveAndAppendTo(metrics.ResourceMetrics())
+		return nil
+	})
+	logConsumer, _ := consumer.NewLogs(func(_ context.Context, ld plog.Logs) error {
+		ld.ResourceLogs().MoveAndAppendTo(logs.ResourceLogs())
+		return nil
+	})
+
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	if signal == "logs" {
+		emitter = NewEmitter(context.Background(), logger, nil, logConsumer)
+	}
+	scraper := NewScraper("golden", logger, client, emitter, config, 60, nil)
+	// time relative filters see the data at the time it was recorded
+	if recordedAt := client.RecordedAt(); !recordedAt.IsZero() {
+		scraper.SetClock(func() time.Time { return recordedAt })
+	}
+	if err = scraper.ScrapeOnce(context.Background()); err != nil {
+		return nil, fmt.Errorf("scrape failed - %v", err)
+	}
+	if unused := client.Unused(); len(unused) > 0 {
+		return nil, fmt.Errorf("fixtures not requested by the queries %v", unused)
+	}
+
+	var output []byte
+	if signal == "logs" {
+		normalizeLogs(logs)
+		output, err = (&plog.JSONMarshaler{}).MarshalLogs(logs)
+	} else {
+		normalizeMetrics(metrics)
+		output, err = (&pmetric.JSONMarshaler{}).MarshalMetrics(metrics)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal output - %v", err)
+	}
+	indented := bytes.Buffer{}
+	json.Indent(&indented, output, "", "  ")
+	indented.WriteString("\n")
+	return indented.Bytes(), nil
+}
+
+// CompareGolden - replays the query file from queryDir against fixtureDir/<name> and compares
+// the output with goldenDir/<name>.json, update rewrites the golden file first
+func CompareGolden(query GoldenQuery, queryDir string, fixtureDir string, goldenDir string, update bool) error {
+	name := strings.TrimSuffix(query.File, filepath.Ext(query.File))
+	actual, err := ReplayQueryFile(filepath.Join(queryDir, query.File), filepath.Join(fixtureDir, name), query.Signal)
+	if err != nil {
+		return err
+	}
+
+	goldenFile := filepath.Join(goldenDir, name+".json")
+	if update {
+		os.MkdirAll(goldenDir, 0o755)
+		if err := os.WriteFile(goldenFile, actual, 0o644); err != nil {
+			return fmt.Errorf("cannot write golden file - %v", err)
+		}
+	}
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		return fmt.Errorf("cannot read golden file, run with -update to create it - %v", err)
+	}
+	if !bytes.Equal(expected, actual) {
+		return fmt.Errorf("%s differs from golden file %s, run with -update if expected:\n%s", query.File, goldenFile, actual)
+	}
+	return nil
+}
+
+// clockTime - zeroes timestamps within a day from now, these come from the clock, not from the data
+func clockTime(ts pcommon.Timestamp) pcommon.Timestamp {
+	if diff := time.Since(ts.AsTime()); diff < 24*time.Hour && diff > -24*time.Hour {
+		return 0
+	}
+	return ts
+}
+
+func normalizeMetrics(metrics pmetric.Metrics) {
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		sms := metrics.ResourceMetrics().At(i).ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			ms := sms.At(j).Metrics()
+			for k := 0; k < ms.Len(); k++ {
+				var dps pmetric.NumberDataPointSlice
+				switch ms.At(k).Type() {
+				case pmetric.MetricTypeGauge:
+					dps = ms.At(k).Gauge().DataPoints()
+				case pmetric.MetricTypeSum:
+					dps = ms.At(k).Sum().DataPoints()
+				default:
+					continue
+				}
+				for l := 0; l < dps.Len(); l++ {
+					dps.At(l).SetStartTimestamp(clockTime(dps.At(l).StartTimestamp()))
+					dps.At(l).SetTimestamp(clockTime(dps.At(l).Timestamp()))
+				}
+			}
+		}
+	}
+}
+
+func normalizeLogs(logs plog.Logs) {
+	for i := 0; i < logs.ResourceLogs().Len(); i++ {
+		sls := logs.ResourceLogs().At(i).ScopeLogs()
+		for j := 0; j < sls.Len(); j++ {
+			lrs := sls.At(j).LogRecords()
+			for k := 0; k < lrs.Len(); k++ {
+				lrs.At(k).SetObservedTimestamp(0)
+				lrs.At(k).SetTimestamp(clockTime(lrs.At(k).Timestamp()))
+			}
+		}
+	}
+}
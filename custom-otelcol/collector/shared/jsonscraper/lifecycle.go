@@ -0,0 +1,196 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ScrapePolicy - what to do when a tick comes while the previous scrape is still running
+type ScrapePolicy string
+
+const (
+	ScrapePolicySkip           ScrapePolicy = "skip"           // drop the tick
+	ScrapePolicyQueue          ScrapePolicy = "queue"          // run once more right after the running scrape ends
+	ScrapePolicyCancelPrevious ScrapePolicy = "cancelPrevious" // cancel the running scrape and start new one when it ends
+)
+
+func (p ScrapePolicy) IsValid() bool {
+	switch p {
+	case "", ScrapePolicySkip, ScrapePolicyQueue, ScrapePolicyCancelPrevious:
+		return true
+	}
+	return false
+}
+
+// scrapeRunner - makes sure at most one scrape of the same schedule runs at a time
+type scrapeRunner struct {
+	name    string
+	logger  *zap.Logger
+	policy  ScrapePolicy
+	timeout time.Duration
+	scrape  func(ctx context.Context) error
+	skipped *atomic.Int64
+	wg      *sync.WaitGroup // tracks running scrapes for Stop, optional
+
+	mutex   sync.Mutex
+	running bool
+	queued  bool
+	cancel  context.CancelFunc
+}
+
+func newScrapeRunner(name string, logger *zap.Logger, policy ScrapePolicy, timeout time.Duration, skipped *atomic.Int64, scrape func(ctx context.Context) error) *scrapeRunner {
+	if policy == "" {
+		policy = ScrapePolicySkip
+	}
+	return &scrapeRunner{
+		name:    name,
+		logger:  logger,
+		policy:  policy,
+		timeout: timeout,
+		scrape:  scrape,
+		skipped: skipped,
+	}
+}
+
+// tick - starts the scrape, or applies the policy if the previous one did not finish yet
+func (r *scrapeRunner) tick(parent context.Context) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.running {
+		r.start(parent)
+		return
+	}
+
+	switch r.policy {
+	case ScrapePolicyQueue:
+		if r.queued {
+			r.skip("previous scrape still running and another one already queued")
+			return
+		}
+		r.queued = true
+	case ScrapePolicyCancelPrevious:
+		r.logger.Sugar().Warnf("Previous scrape of %s still running, cancelling it", r.name)
+		r.cancel()
+		r.queued = true
+	default:
+		r.skip("previous scrape still running")
+	}
+}
+
+func (r *scrapeRunner) skip(reason string) {
+	skipped := r.skipped.Add(1)
+	r.logger.Sugar().Warnf("Skipping scrape of %s - %s, skipped ticks so far: %d", r.name, reason, skipped)
+}
+
+// start - must be called with mutex locked
+func (r *scrapeRunner) start(parent context.Context) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, r.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	r.running = true
+	r.cancel = cancel
+
+	if r.wg != nil {
+		r.wg.Add(1)
+	}
+	go func() {
+		if r.wg != nil {
+			defer r.wg.Done()
+		}
+		defer cancel()
+		err := r.scrape(ctx)
+		if err != nil {
+			r.logger.Sugar().Errorf("Error scrapping %s: %v", r.name, err)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			r.logger.Sugar().Warnf("Scrape of %s did not finish within %v", r.name, r.timeout)
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.running = false
+		if r.queued && parent.Err() == nil {
+			r.queued = false
+			r.start(parent)
+		}
+	}()
+}
+
+// scraperLifecycle - running state of the scraper shared by all copies of the Scraper value
+type scraperLifecycle struct {
+	mutex       sync.Mutex
+	ctx         context.Context // cancelled by end, nil before begin
+	cancel      context.CancelFunc
+	stopTicking context.CancelFunc // stops the schedules of the current config, running scrapes go on
+	reload      sync.Mutex         // one reload at a time
+	wg          sync.WaitGroup
+}
+
+// begin - creates the context cancelled by end
+func (l *scraperLifecycle) begin() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+}
+
+// startTicking - calls start with the scraper's context and the context of ticking, which is
+// cancelled by end or pause, returns false once the scraper ended
+func (l *scraperLifecycle) startTicking(start func(ctx context.Context, ticking context.Context)) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.ctx == nil || l.ctx.Err() != nil {
+		return false
+	}
+	ticking, stopTicking := context.WithCancel(l.ctx)
+	l.stopTicking = stopTicking
+	// goroutines are added to wg under the mutex, so that they are never added after end started waiting
+	start(l.ctx, ticking)
+	return true
+}
+
+// pause - stops ticking and waits until running scrapes end, returns false when the scraper
+// is not running
+func (l *scraperLifecycle) pause() bool {
+	l.mutex.Lock()
+	if l.ctx == nil || l.ctx.Err() != nil {
+		l.mutex.Unlock()
+		return false
+	}
+	l.stopTicking()
+	l.mutex.Unlock()
+
+	l.wg.Wait()
+	return true
+}
+
+// end - cancels the context and waits for all tracked goroutines to finish or ctx to expire
+func (l *scraperLifecycle) end(ctx context.Context) error {
+	l.mutex.Lock()
+	if l.cancel != nil {
+		l.cancel()
+	}
+	l.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scrapes still running at shutdown - %v", ctx.Err())
+	}
+}
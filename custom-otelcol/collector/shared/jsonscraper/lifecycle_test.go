@@ -0,0 +1,247 @@
+package jsonscraper
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// blockingScrape - scrape which runs until released or cancelled
+type blockingScrape struct {
+	started   atomic.Int64
+	cancelled atomic.Int64
+	release   chan struct{}
+}
+
+func (b *blockingScrape) scrape(ctx context.Context) error {
+	b.started.Add(1)
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		b.cancelled.Add(1)
+	}
+	return nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScrapePolicySkip(t *testing.T) {
+	b := &blockingScrape{release: make(chan struct{})}
+	skipped := &atomic.Int64{}
+	runner := newScrapeRunner("test", zap.NewNop(), ScrapePolicySkip, 0, skipped, b.scrape)
+
+	runner.tick(context.Background())
+	runner.tick(context.Background())
+	runner.tick(context.Background())
+	if skipped.Load() != 2 {
+		t.Fatalf("expected: 2 skipped != actual: %d", skipped.Load())
+	}
+
+	close(b.release)
+	waitFor(t, "scrape end", func() bool {
+		runner.mutex.Lock()
+		defer runner.mutex.Unlock()
+		return !runner.running
+	})
+	if b.started.Load() != 1 {
+		t.Fatalf("expected: 1 scrape != actual: %d", b.started.Load())
+	}
+}
+
+func TestScrapePolicyQueue(t *testing.T) {
+	b := &blockingScrape{release: make(chan struct{})}
+	skipped := &atomic.Int64{}
+	runner := newScrapeRunner("test", zap.NewNop(), ScrapePolicyQueue, 0, skipped, b.scrape)
+
+	runner.tick(context.Background())
+	runner.tick(context.Background())
+	runner.tick(context.Background())
+	if skipped.Load() != 1 {
+		t.Fatalf("expected: 1 skipped != actual: %d", skipped.Load())
+	}
+
+	close(b.release)
+	waitFor(t, "queued scrape", func() bool { return b.started.Load() == 2 })
+}
+
+func TestScrapePolicyCancelPreviousAndTimeout(t *testing.T) {
+	b := &blockingScrape{release: make(chan struct{})}
+	skipped := &atomic.Int64{}
+	runner := newScrapeRunner("test", zap.NewNop(), ScrapePolicyCancelPrevious, 0, skipped, b.scrape)
+
+	runner.tick(context.Background())
+	waitFor(t, "first scrape", func() bool { return b.started.Load() == 1 })
+	runner.tick(context.Background())
+	waitFor(t, "second scrape", func() bool { return b.started.Load() == 2 })
+	if b.cancelled.Load() != 1 {
+		t.Fatalf("expected: 1 cancelled != actual: %d", b.cancelled.Load())
+	}
+
+	timed := &blockingScrape{release: make(chan struct{})}
+	runner = newScrapeRunner("test", zap.NewNop(), ScrapePolicySkip, 10*time.Millisecond, skipped, timed.scrape)
+	runner.tick(context.Background())
+	waitFor(t, "timeout", func() bool { return timed.cancelled.Load() == 1 })
+	close(b.release)
+}
+
+// hangingClient - client whose requests hang until the scrape context is cancelled
+type hangingClient struct {
+	requests atomic.Int64
+	release  chan struct{}
+}
+
+func (c *hangingClient) Login(ctx context.Context) error {
+	return nil
+}
+
+func (c *hangingClient) Logout(ctx context.Context) error {
+	return nil
+}
+
+func (c *hangingClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	c.requests.Add(1)
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-c.release:
+		return `{}`, nil
+	}
+}
+
+func newHangingScraper(t *testing.T, client *hangingClient) *Scraper {
+	scraper := newTestScraper(t, client)
+	err := scraper.config.AddQueryRules([]byte(parallelTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	return scraper
+}
+
+func TestStopLeavesNoGoroutines(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	for i := 0; i < 3; i++ {
+		client := &hangingClient{release: make(chan struct{})}
+		scraper := newHangingScraper(t, client)
+		scraper.Run()
+		waitFor(t, "request in flight", func() bool { return client.requests.Load() > 0 })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := scraper.Stop(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("Stop failed - %v", err)
+		}
+	}
+
+	waitFor(t, "goroutines to end", func() bool { return runtime.NumGoroutine() <= baseline })
+}
+
+func TestStopTimesOut(t *testing.T) {
+	client := &hangingClient{release: make(chan struct{})}
+	scraper := newHangingScraper(t, client)
+	// a scrape which ignores cancellation
+	scraper.scrapperClient = &ignoringClient{hangingClient: client}
+	scraper.Run()
+	waitFor(t, "request in flight", func() bool { return client.requests.Load() > 0 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := scraper.Stop(ctx)
+	if err == nil {
+		t.Fatalf("expected Stop to time out")
+	}
+
+	close(client.release)
+	err = scraper.Stop(context.Background())
+	if err != nil {
+		t.Fatalf("Stop after release failed - %v", err)
+	}
+}
+
+// ignoringClient - request does not return before released, even when cancelled
+type ignoringClient struct {
+	*hangingClient
+}
+
+func (c *ignoringClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	return c.hangingClient.DoRequest(context.Background(), method, url, payload)
+}
+
+// slowInventoryClient - requests of /inventory hang until cancelled, the others return at once
+type slowInventoryClient struct {
+	faults    atomic.Int64
+	inventory atomic.Int64
+	cancelled atomic.Int64
+}
+
+func (c *slowInventoryClient) Login(ctx context.Context) error {
+	return nil
+}
+
+func (c *slowInventoryClient) Logout(ctx context.Context) error {
+	return nil
+}
+
+func (c *slowInventoryClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	if url != "/inventory" {
+		c.faults.Add(1)
+		return `{}`, nil
+	}
+	c.inventory.Add(1)
+	<-ctx.Done()
+	c.cancelled.Add(1)
+	return "", ctx.Err()
+}
+
+func TestScrapePolicyPerSchedule(t *testing.T) {
+	client := &slowInventoryClient{}
+	scraper := newTestScraper(t, client)
+	scraper.config.OverlapPolicy = ScrapePolicyCancelPrevious
+	err := scraper.config.AddQueryRules([]byte(`
+queries:
+- name: Inventory
+  resource:
+    name: test
+  scope:
+    name: test
+  interval: 3600
+  rules:
+    query: /inventory
+- name: Faults
+  resource:
+    name: test
+  scope:
+    name: test
+  interval: 1
+  rules:
+    query: /faults
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+
+	scraper.Run()
+	defer scraper.Stop(context.Background())
+
+	// faults keep their cadence while the slow inventory scrape runs and is not cancelled by them
+	waitFor(t, "faults scraped twice", func() bool { return client.faults.Load() >= 2 })
+	if client.inventory.Load() != 1 || client.cancelled.Load() != 0 {
+		t.Fatalf("expected: 1 running inventory scrape != actual: %d started, %d cancelled", client.inventory.Load(), client.cancelled.Load())
+	}
+	if scraper.skippedTicks.Load() != 0 {
+		t.Fatalf("expected: 0 skipped != actual: %d", scraper.skippedTicks.Load())
+	}
+}
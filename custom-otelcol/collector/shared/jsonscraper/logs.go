@@ -0,0 +1,113 @@
+package jsonscraper
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/antchfx/jsonquery"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const defaultLogTypeAttribute = "log.type"
+
+// logValue - evaluated content of one log record
+type logValue struct {
+	body           any // string or structured value - map, slice
+	severity       string
+	severityNumber plog.SeverityNumber // unspecified means derived from severity by the convertor
+	timestamp      time.Time           // zero means unknown, observed time is used
+	traceID        pcommon.TraceID
+	spanID         pcommon.SpanID
+}
+
+func (l *LogEmit) logTypeAttribute() string {
+	if l.LogTypeAttribute != "" {
+		return l.LogTypeAttribute
+	}
+	return defaultLogTypeAttribute
+}
+
+func (g *Scraper) evaluateLog(emit *LogEmit, doc *jsonquery.Node, scContext *scraperContext) (*logValue, error) {
+	value := &logValue{}
+
+	switch {
+	case emit.BodyFrom != "":
+		bodyAny, err := g.evaluateRawValueFrom(doc, emit.BodyFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", emit.BodyFrom, err)
+		}
+		value.body = bodyAny
+	case emit.MessageFrom != "":
+		messageAny, err := g.evaluateValueFrom(doc, emit.MessageFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", emit.MessageFrom, err)
+		}
+		value.body = fmt.Sprintf("%v", messageAny)
+	default:
+		value.body = ""
+	}
+
+	if emit.SeverityFrom != "" {
+		serviceNativeSeverityAny, err := g.evaluateValueFrom(doc, emit.SeverityFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", emit.SeverityFrom, err)
+		}
+		value.severity = fmt.Sprintf("%v", serviceNativeSeverityAny)
+	}
+	if emit.SeverityNumberFrom != "" {
+		number, err := g.evaluateNumber(doc, emit.SeverityNumberFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", emit.SeverityNumberFrom, err)
+		}
+		if number < float64(plog.SeverityNumberTrace) || number > float64(plog.SeverityNumberFatal4) {
+			return nil, fmt.Errorf("severity number %v out of range 1 - 24", number)
+		}
+		value.severityNumber = plog.SeverityNumber(number)
+	}
+	timestamp, err := g.evaluateTimestamp(emit.TimestampFrom, emit.TimestampFormat, emit.Timezone, doc, scContext)
+	if err != nil {
+		g.logger.Sugar().Warnf("Log record of type %s gets observed time as timestamp - %v", emit.LogType, err)
+	}
+	value.timestamp = timestamp
+
+	if emit.TraceIdFrom != "" {
+		traceIdAny, err := g.evaluateValueFrom(doc, emit.TraceIdFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", emit.TraceIdFrom, err)
+		}
+		if err = parseHexId(fmt.Sprintf("%v", traceIdAny), value.traceID[:]); err != nil {
+			return nil, fmt.Errorf("trace id - %v", err)
+		}
+	}
+	if emit.SpanIdFrom != "" {
+		spanIdAny, err := g.evaluateValueFrom(doc, emit.SpanIdFrom, scContext)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot evaluate expr %s - %v", emit.SpanIdFrom, err)
+		}
+		if err = parseHexId(fmt.Sprintf("%v", spanIdAny), value.spanID[:]); err != nil {
+			return nil, fmt.Errorf("span id - %v", err)
+		}
+	}
+
+	return value, nil
+}
+
+// parseHexId - parses hex encoded trace or span id, empty string leaves the id empty
+func parseHexId(id string, dest []byte) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil
+	}
+	decoded, err := hex.DecodeString(id)
+	if err != nil {
+		return fmt.Errorf("%s is not hex string - %v", id, err)
+	}
+	if len(decoded) != len(dest) {
+		return fmt.Errorf("%s has %d bytes, expected %d", id, len(decoded), len(dest))
+	}
+	copy(dest, decoded)
+	return nil
+}
@@ -0,0 +1,95 @@
+package jsonscraper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+const logsTestQueries = `
+queries:
+- name: Faults
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /faults
+    select: imdata//faultRecord
+    forEach:
+      emitLogs:
+      - logType: fault
+        bodyFrom: attributes
+        severityFrom: attributes/severity
+        timestampFrom: attributes/created
+      - logType: fault.raised
+        logTypeAttribute: event.name
+        messageFrom: attributes/descr
+        severityNumberFrom: =17
+        traceIdFrom: attributes/traceId
+        spanIdFrom: attributes/spanId
+`
+
+func TestStructuredLogs(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(logsTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/faults": `{"imdata":[{"faultRecord":{"attributes":{"code":"F0532","descr":"Port is down","severity":"major","created":"2023-05-30T15:16:26.896+02:00","traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708"}}}]}`,
+		},
+	}
+
+	records := []plog.LogRecord{}
+	logConsumer, err := consumer.NewLogs(func(_ context.Context, logs plog.Logs) error {
+		lrs := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for i := 0; i < lrs.Len(); i++ {
+			records = append(records, lrs.At(i))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, nil, logConsumer)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected: 2 records != actual: %d", len(records))
+	}
+
+	structured := records[0]
+	body, ok := structured.Body().AsRaw().(map[string]any)
+	if !ok || body["code"] != "F0532" || body["descr"] != "Port is down" {
+		t.Fatalf("expected fault attributes in body, got %v", structured.Body().AsRaw())
+	}
+	logType, _ := structured.Attributes().Get("log.type")
+	if logType.Str() != "fault" {
+		t.Fatalf("expected: log.type fault != actual: %v", structured.Attributes().AsRaw())
+	}
+	if structured.SeverityNumber() != plog.SeverityNumberError2 || !structured.TraceID().IsEmpty() {
+		t.Fatalf("unexpected severity %v or trace id %v", structured.SeverityNumber(), structured.TraceID())
+	}
+
+	event := records[1]
+	if event.Body().Str() != "Port is down" || event.SeverityNumber() != plog.SeverityNumberError {
+		t.Fatalf("unexpected body %v or severity %v", event.Body().AsRaw(), event.SeverityNumber())
+	}
+	eventName, _ := event.Attributes().Get("event.name")
+	if eventName.Str() != "fault.raised" {
+		t.Fatalf("expected: event.name fault.raised != actual: %v", event.Attributes().AsRaw())
+	}
+	if event.TraceID().String() != "0102030405060708090a0b0c0d0e0f10" || !reflect.DeepEqual([8]byte(event.SpanID()), [8]byte{1, 2, 3, 4, 5, 6, 7, 8}) {
+		t.Fatalf("unexpected trace id %v or span id %v", event.TraceID(), event.SpanID())
+	}
+}
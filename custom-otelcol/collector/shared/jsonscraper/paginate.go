@@ -0,0 +1,332 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// ScraperHeaderClient - optional extension of ScraperClient for clients which are able
+// to return response headers. Needed by "link" pagination strategy.
+type ScraperHeaderClient interface {
+	DoRequestWithHeaders(ctx context.Context, method string, url string, payload *string) (string, http.Header, error)
+}
+
+type PaginateStrategy string
+
+const (
+	PaginateAci    PaginateStrategy = "aci"    // page & page-size URL params, totalCount in response
+	PaginateOData  PaginateStrategy = "odata"  // $top & $skip URL params, $inlinecount=allpages, Count with Results in response
+	PaginateCursor PaginateStrategy = "cursor" // next page token taken from response and sent as URL param
+	PaginateLink   PaginateStrategy = "link"   // next page URL taken from Link header with rel="next"
+
+	defaultPageSize = 100
+	defaultMaxPages = 100
+)
+
+type Paginate struct {
+	Strategy    PaginateStrategy `yaml:"strategy"`
+	PageSize    int              `yaml:"pageSize"`    // items per page, not used by link strategy
+	MaxPages    int              `yaml:"maxPages"`    // safety stop, default 100
+	ItemsPath   string           `yaml:"itemsPath"`   // slash separated path to array with items in response
+	TotalPath   string           `yaml:"totalPath"`   // slash separated path to total count of items in response
+	CursorPath  string           `yaml:"cursorPath"`  // jsonquery expression returning next page token (cursor strategy)
+	CursorParam string           `yaml:"cursorParam"` // URL parameter carrying the token (cursor strategy)
+}
+
+func (p *Paginate) pageSize() int {
+	if p.PageSize > 0 {
+		return p.PageSize
+	}
+	return defaultPageSize
+}
+
+func (p *Paginate) maxPages() int {
+	if p.MaxPages > 0 {
+		return p.MaxPages
+	}
+	return defaultMaxPages
+}
+
+func (p *Paginate) itemsPath() string {
+	if p.ItemsPath != "" {
+		return p.ItemsPath
+	}
+	switch p.Strategy {
+	case PaginateAci:
+		return "imdata"
+	case PaginateOData:
+		return "Results"
+	}
+	return ""
+}
+
+func (p *Paginate) totalPath() string {
+	if p.TotalPath != "" {
+		return p.TotalPath
+	}
+	switch p.Strategy {
+	case PaginateAci:
+		return "totalCount"
+	case PaginateOData:
+		return "Count"
+	}
+	return ""
+}
+
+func (p *Paginate) validate() error {
+	switch p.Strategy {
+	case PaginateAci, PaginateOData:
+	case PaginateCursor:
+		if p.CursorPath == "" || p.CursorParam == "" {
+			return fmt.Errorf("cursor pagination requires cursorPath and cursorParam")
+		}
+		if p.ItemsPath == "" {
+			return fmt.Errorf("cursor pagination requires itemsPath")
+		}
+	case PaginateLink:
+		if p.ItemsPath == "" {
+			return fmt.Errorf("link pagination requires itemsPath")
+		}
+	default:
+		return fmt.Errorf("unknown pagination strategy %s", p.Strategy)
+	}
+	return nil
+}
+
+// pageURL - returns URL of the page with index pageNo for offset based strategies
+func (p *Paginate) pageURL(uri string, pageNo int) string {
+	size := p.pageSize()
+	switch p.Strategy {
+	case PaginateAci:
+		uri = setQueryParam(uri, "page", strconv.Itoa(pageNo))
+		uri = setQueryParam(uri, "page-size", strconv.Itoa(size))
+	case PaginateOData:
+		uri = setQueryParam(uri, "$top", strconv.Itoa(size))
+		uri = setQueryParam(uri, "$skip", strconv.Itoa(pageNo*size))
+		// $count=true would return the Count only, without Results
+		uri = setQueryParam(uri, "$inlinecount", "allpages")
+	}
+	return uri
+}
+
+// getPagedDataFromService - reads all pages of the response and merges the items of all pages
+// into the items array of the first page, so the result looks like a single document
+func (g *Scraper) getPagedDataFromService(ctx context.Context, paginate *Paginate, format string, method string, uri string, payload *string) (*jsonquery.Node, error) {
+	if err := paginate.validate(); err != nil {
+		return nil, err
+	}
+
+	itemsPath := paginate.itemsPath()
+	var merged map[string]any
+	var mergedItems []any
+	total := -1
+	nextURL := uri
+	if paginate.Strategy == PaginateAci || paginate.Strategy == PaginateOData {
+		nextURL = paginate.pageURL(uri, 0)
+	}
+
+	pageNo := 0
+	for ; pageNo < paginate.maxPages() && nextURL != ""; pageNo++ {
+		response, headers, err := g.requestService(ctx, paginate.Strategy == PaginateLink, method, nextURL, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		decoded, err := decodeResponse(format, response)
+		if err != nil {
+			return nil, fmt.Errorf("Error in parsing page %d from service %s, method %s, uri %s - %v", pageNo, g.name, method, nextURL, err)
+		}
+		page, ok := decoded.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("Page %d from service %s, uri %s is not an object, cannot paginate", pageNo, g.name, nextURL)
+		}
+		items, err := getItemsAtPath(page, itemsPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error in reading page %d from service %s, uri %s - %v", pageNo, g.name, nextURL, err)
+		}
+
+		if merged == nil {
+			merged = page
+			if paginate.totalPath() != "" {
+				total = getCountAtPath(page, paginate.totalPath())
+			}
+		}
+		mergedItems = append(mergedItems, items...)
+
+		nextURL = ""
+		switch paginate.Strategy {
+		case PaginateAci, PaginateOData:
+			if len(items) == 0 || len(items) < paginate.pageSize() {
+				break
+			}
+			if total >= 0 && len(mergedItems) >= total {
+				break
+			}
+			nextURL = paginate.pageURL(uri, pageNo+1)
+		case PaginateCursor:
+			doc, err := toNode(page)
+			if err != nil {
+				return nil, fmt.Errorf("Error in parsing page %d from service %s, method %s, uri %s - %v", pageNo, g.name, method, nextURL, err)
+			}
+			cursorNode := jsonquery.FindOne(doc, paginate.CursorPath)
+			if cursorNode != nil && len(items) > 0 {
+				cursor := fmt.Sprintf("%v", cursorNode.Value())
+				if cursor != "" && cursor != "<nil>" {
+					nextURL = setQueryParam(uri, paginate.CursorParam, url.QueryEscape(cursor))
+				}
+			}
+		case PaginateLink:
+			nextURL = nextLinkURL(headers)
+		}
+	}
+
+	if nextURL != "" {
+		g.logger.Sugar().Warnf("Pagination of %s stopped after %d pages, some items were not read", uri, pageNo)
+	}
+
+	err := setItemsAtPath(merged, itemsPath, mergedItems)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := toNode(merged)
+	if err != nil {
+		return nil, fmt.Errorf("Error in parsing merged pages from service %s, uri %s - %v", g.name, uri, err)
+	}
+
+	g.logger.Sugar().Debugf("Read %d items in %d pages from %s", len(mergedItems), pageNo, uri)
+
+	return doc, nil
+}
+
+func (g *Scraper) requestService(ctx context.Context, withHeaders bool, method string, uri string, payload *string) (string, http.Header, error) {
+	var response string
+	var headers http.Header
+	var err error
+	if withHeaders {
+		headerClient, ok := g.scrapperClient.(ScraperHeaderClient)
+		if !ok {
+			return "", nil, fmt.Errorf("Client of service %s does not provide response headers needed for link pagination", g.name)
+		}
+		response, headers, err = headerClient.DoRequestWithHeaders(ctx, method, uri, payload)
+	} else {
+		response, err = g.scrapperClient.DoRequest(ctx, method, uri, payload)
+	}
+	if err != nil {
+		var pld string
+		if payload != nil {
+			pld = *payload
+		}
+		return "", nil, fmt.Errorf("Error in getting data from service %s, method %s, uri %s, payload %s - %v", g.name, method, uri, pld, err)
+	}
+	return response, headers, nil
+}
+
+// setQueryParam - sets or replaces one URL query parameter, the rest of the URL is kept untouched
+// so already escaped filters are not re-encoded
+func setQueryParam(uri string, name string, value string) string {
+	path, query, hasQuery := strings.Cut(uri, "?")
+	if !hasQuery || query == "" {
+		return path + "?" + name + "=" + value
+	}
+
+	params := strings.Split(query, "&")
+	replaced := false
+	for i, param := range params {
+		paramName, _, _ := strings.Cut(param, "=")
+		if paramName == name {
+			params[i] = name + "=" + value
+			replaced = true
+		}
+	}
+	if !replaced {
+		params = append(params, name+"="+value)
+	}
+	return path + "?" + strings.Join(params, "&")
+}
+
+var linkNextRegexp = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+// nextLinkURL - returns path and query of the rel="next" link, the clients prepend the host themselves
+func nextLinkURL(headers http.Header) string {
+	for _, link := range headers.Values("Link") {
+		match := linkNextRegexp.FindStringSubmatch(link)
+		if match == nil {
+			continue
+		}
+		next, err := url.Parse(match[1])
+		if err != nil {
+			return ""
+		}
+		if next.RawQuery == "" {
+			return next.EscapedPath()
+		}
+		return next.EscapedPath() + "?" + next.RawQuery
+	}
+	return ""
+}
+
+func getItemsAtPath(doc map[string]any, path string) ([]any, error) {
+	var current any = doc
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		currentMap, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("items path %s does not lead to array", path)
+		}
+		current, ok = currentMap[part]
+		if !ok || current == nil {
+			return []any{}, nil
+		}
+	}
+	items, ok := current.([]any)
+	if !ok {
+		return nil, fmt.Errorf("items path %s does not lead to array, found %T", path, current)
+	}
+	return items, nil
+}
+
+func setItemsAtPath(doc map[string]any, path string, items []any) error {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	current := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			current[part] = next
+		}
+		current = next
+	}
+	if items == nil {
+		items = []any{}
+	}
+	current[parts[len(parts)-1]] = items
+	return nil
+}
+
+func getCountAtPath(doc map[string]any, path string) int {
+	var current any = doc
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		currentMap, ok := current.(map[string]any)
+		if !ok {
+			return -1
+		}
+		current = currentMap[part]
+	}
+	switch count := current.(type) {
+	case float64:
+		return int(count)
+	case string:
+		val, err := strconv.Atoi(count)
+		if err != nil {
+			return -1
+		}
+		return val
+	}
+	return -1
+}
@@ -0,0 +1,148 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/antchfx/jsonquery"
+	"go.uber.org/zap"
+)
+
+type fakeClient struct {
+	responses map[string]string
+	headers   map[string]http.Header
+	requests  []string
+	mutex     sync.Mutex
+}
+
+func (c *fakeClient) Login(ctx context.Context) error {
+	return nil
+}
+
+func (c *fakeClient) Logout(ctx context.Context) error {
+	return nil
+}
+
+func (c *fakeClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	response, _, err := c.DoRequestWithHeaders(ctx, method, url, payload)
+	return response, err
+}
+
+func (c *fakeClient) DoRequestWithHeaders(ctx context.Context, method string, url string, payload *string) (string, http.Header, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, url)
+	response, ok := c.responses[url]
+	if !ok {
+		return "", nil, fmt.Errorf("no response for %s", url)
+	}
+	return response, c.headers[url], nil
+}
+
+func newTestScraper(t *testing.T, client ScraperClient) *Scraper {
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, nil, nil)
+	scraper := NewScraper("test", logger, client, emitter, NewScraperConfig(), 60, nil)
+	return &scraper
+}
+
+func TestPaginateAci(t *testing.T) {
+	client := &fakeClient{
+		responses: map[string]string{
+			"/api/class/fabricNode.json?page=0&page-size=2": `{"totalCount":"5","imdata":[{"fabricNode":{"attributes":{"name":"n1"}}},{"fabricNode":{"attributes":{"name":"n2"}}}]}`,
+			"/api/class/fabricNode.json?page=1&page-size=2": `{"totalCount":"5","imdata":[{"fabricNode":{"attributes":{"name":"n3"}}},{"fabricNode":{"attributes":{"name":"n4"}}}]}`,
+			"/api/class/fabricNode.json?page=2&page-size=2": `{"totalCount":"5","imdata":[{"fabricNode":{"attributes":{"name":"n5"}}}]}`,
+		},
+	}
+	scraper := newTestScraper(t, client)
+
+	paginate := &Paginate{Strategy: PaginateAci, PageSize: 2}
+	doc, err := scraper.getPagedDataFromService(context.Background(), paginate, "", "GET", "/api/class/fabricNode.json", nil)
+	if err != nil {
+		t.Fatalf("Cannot read pages - %v", err)
+	}
+
+	names := jsonquery.Find(doc, "imdata//fabricNode/attributes/name")
+	if len(names) != 5 {
+		t.Fatalf("expected: 5 items != actual: %d, requests %v", len(names), client.requests)
+	}
+	if names[4].Value() != "n5" {
+		t.Fatalf("expected: n5 != actual: %v", names[4].Value())
+	}
+}
+
+func TestPaginateODataMaxPages(t *testing.T) {
+	client := &fakeClient{
+		responses: map[string]string{
+			"/api/v1/view/Servers?$filter=x&$skip=0&$top=1&$inlinecount=allpages": `{"Count":3,"Results":[{"Moid":"a"}]}`,
+			"/api/v1/view/Servers?$filter=x&$skip=1&$top=1&$inlinecount=allpages": `{"Count":3,"Results":[{"Moid":"b"}]}`,
+			"/api/v1/view/Servers?$filter=x&$skip=2&$top=1&$inlinecount=allpages": `{"Count":3,"Results":[{"Moid":"c"}]}`,
+		},
+	}
+	scraper := newTestScraper(t, client)
+
+	paginate := &Paginate{Strategy: PaginateOData, PageSize: 1, MaxPages: 2}
+	doc, err := scraper.getPagedDataFromService(context.Background(), paginate, "", "GET", "/api/v1/view/Servers?$filter=x&$skip=0", nil)
+	if err != nil {
+		t.Fatalf("Cannot read pages - %v", err)
+	}
+
+	moids := jsonquery.Find(doc, "Results/*/Moid")
+	if len(moids) != 2 {
+		t.Fatalf("expected: 2 items != actual: %d, requests %v", len(moids), client.requests)
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	tests := []struct {
+		paginate Paginate
+		uri      string
+		pageNo   int
+		expected string
+	}{
+		{Paginate{Strategy: PaginateAci, PageSize: 50}, "/api/class/faultInst.json", 2, "/api/class/faultInst.json?page=2&page-size=50"},
+		{Paginate{Strategy: PaginateOData}, "/api/v1/view/Servers", 0, "/api/v1/view/Servers?$top=100&$skip=0&$inlinecount=allpages"},
+		{Paginate{Strategy: PaginateOData, PageSize: 10}, "/api/v1/view/Servers?$filter=(Name%20eq%20'a')&$skip=5", 3, "/api/v1/view/Servers?$filter=(Name%20eq%20'a')&$skip=30&$top=10&$inlinecount=allpages"},
+	}
+	for _, test := range tests {
+		if actual := test.paginate.pageURL(test.uri, test.pageNo); actual != test.expected {
+			t.Errorf("expected: %s != actual: %s", test.expected, actual)
+		}
+	}
+}
+
+func TestPaginateCursorAndLink(t *testing.T) {
+	client := &fakeClient{
+		responses: map[string]string{
+			"/items":              `{"items":[{"id":"1"}],"next":"abc"}`,
+			"/items?token=abc":    `{"items":[{"id":"2"}],"next":""}`,
+			"/linked":             `{"data":{"items":[{"id":"1"}]}}`,
+			"/linked?cursor=xyz=": `{"data":{"items":[{"id":"2"}]}}`,
+		},
+		headers: map[string]http.Header{
+			"/linked": {"Link": []string{`<https://api.example.com/linked?cursor=xyz=>; rel="next", <https://api.example.com/linked>; rel="first"`}},
+		},
+	}
+	scraper := newTestScraper(t, client)
+
+	paginate := &Paginate{Strategy: PaginateCursor, ItemsPath: "items", CursorPath: "next", CursorParam: "token"}
+	doc, err := scraper.getPagedDataFromService(context.Background(), paginate, "", "GET", "/items", nil)
+	if err != nil {
+		t.Fatalf("Cannot read cursor pages - %v", err)
+	}
+	if ids := jsonquery.Find(doc, "items/*/id"); len(ids) != 2 {
+		t.Fatalf("expected: 2 items != actual: %d, requests %v", len(ids), client.requests)
+	}
+
+	paginate = &Paginate{Strategy: PaginateLink, ItemsPath: "data/items"}
+	doc, err = scraper.getPagedDataFromService(context.Background(), paginate, "", "GET", "/linked", nil)
+	if err != nil {
+		t.Fatalf("Cannot read linked pages - %v", err)
+	}
+	if ids := jsonquery.Find(doc, "data/items/*/id"); len(ids) != 2 {
+		t.Fatalf("expected: 2 items != actual: %d, requests %v", len(ids), client.requests)
+	}
+}
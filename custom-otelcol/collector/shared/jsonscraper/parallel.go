@@ -0,0 +1,180 @@
+package jsonscraper
+
+import (
+	"context"
+	"sync"
+
+	"github.com/antchfx/jsonquery"
+	expr "github.com/chrlic/otelcol-cust/collector/shared/expressions"
+)
+
+// pendingEmits - emits of a parallel branch waiting to be flushed in the order a serial run would produce
+type pendingEmits []func()
+
+// pendingReducerAdds - values a parallel branch adds to reducers of the rules enclosing the branch
+type pendingReducerAdds []reducerAdd
+
+type reducerAdd struct {
+	reducers *expr.Reducers
+	level    int // stack level of the rule declaring the reducer
+	name     string
+	group    string
+	value    any
+}
+
+func (a reducerAdd) apply() error {
+	return a.reducers.Add(a.name, a.group, a.value)
+}
+
+// branchSlots - query wide limit of concurrently running ForEach branches
+type branchSlots chan struct{}
+
+func newBranchSlots(size int) *branchSlots {
+	if size <= 0 {
+		return nil
+	}
+	slots := make(branchSlots, size)
+	return &slots
+}
+
+// tryAcquire never blocks, when no slot is free the caller runs the branch itself,
+// so nested ForEach levels cannot deadlock on the shared limit
+func (s *branchSlots) tryAcquire() bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case *s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *branchSlots) release() {
+	if s == nil {
+		return
+	}
+	<-*s
+}
+
+func (g *Scraper) forEachConcurrency(rule *Rule, query *Query) int {
+	if rule.MaxConcurrency > 0 {
+		return rule.MaxConcurrency
+	}
+	if query != nil && query.MaxConcurrency > 0 {
+		return query.MaxConcurrency
+	}
+	return 1
+}
+
+// runForEach - processes selected items with rule.ForEach, up to rule's (or query's) maxConcurrency
+// items at a time. Each item runs on its own copy of the context and emits are flushed in items' order.
+func (g *Scraper) runForEach(rule *Rule, list []*jsonquery.Node, scContext *scraperContext, workers int) {
+	rulePath := scContext.rulePath
+	scContext.rulePath = rulePath + "/forEach"
+	defer func() {
+		scContext.rulePath = rulePath
+	}()
+
+	if workers <= 1 || len(list) <= 1 {
+		for _, subDoc := range list {
+			err := g.runRuleNew(rule.ForEach, subDoc, scContext)
+			if err != nil {
+				g.logger.Sugar().Errorf("Rule processing failed %v: %v - %v", rule.ForEach, scContext, err)
+			}
+		}
+		return
+	}
+
+	branches := make([]*scraperContext, len(list))
+	local := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+
+	for i, subDoc := range list {
+		branch := scContext.branch()
+		branches[i] = branch
+
+		local <- struct{}{}
+		if !scContext.slots.tryAcquire() {
+			// query wide limit reached - process the item in this goroutine
+			g.runForEachItem(rule, subDoc, branch)
+			<-local
+			continue
+		}
+
+		wg.Add(1)
+		go func(subDoc *jsonquery.Node, branch *scraperContext) {
+			defer func() {
+				scContext.slots.release()
+				<-local
+				wg.Done()
+			}()
+			g.runForEachItem(rule, subDoc, branch)
+		}(subDoc, branch)
+	}
+	wg.Wait()
+
+	for _, branch := range branches {
+		if err := scContext.mergeReducerAdds(branch); err != nil {
+			g.logger.Sugar().Errorf("Cannot add values of parallel branch to reducers - %v", err)
+		}
+		scContext.mergeEmits(branch)
+	}
+}
+
+func (g *Scraper) runForEachItem(rule *Rule, subDoc *jsonquery.Node, branch *scraperContext) {
+	defer g.recoverBranch()
+
+	err := g.runRuleNew(rule.ForEach, subDoc, branch)
+	if err != nil {
+		g.logger.Sugar().Errorf("Rule processing failed %v: %v - %v", rule.ForEach, branch, err)
+	}
+}
+
+// scrapeQueries - runs queries with up to config's maxConcurrency queries at a time,
+// emits of parallel queries are flushed in the order of queries in the config
+func (g *Scraper) scrapeQueries(ctx context.Context, queries []*Query, interval int, batch *emitBatch) {
+	workers := g.config.MaxConcurrency
+	if workers <= 1 || len(queries) <= 1 {
+		for _, q := range queries {
+			if ctx.Err() != nil {
+				return
+			}
+			g.scrapeOneQuery(ctx, q, interval, batch, nil)
+		}
+		return
+	}
+
+	pending := make([]*pendingEmits, len(queries))
+	local := make(chan struct{}, workers)
+	wg := sync.WaitGroup{}
+
+	for i, q := range queries {
+		pending[i] = &pendingEmits{}
+		local <- struct{}{}
+		if ctx.Err() != nil {
+			<-local
+			break
+		}
+		wg.Add(1)
+		go func(q *Query, queryPending *pendingEmits) {
+			defer func() {
+				<-local
+				wg.Done()
+			}()
+			defer g.recoverBranch()
+			g.scrapeOneQuery(ctx, q, interval, batch, queryPending)
+		}(q, pending[i])
+	}
+	wg.Wait()
+
+	for _, queryPending := range pending {
+		if queryPending == nil {
+			continue
+		}
+		for _, emitFunc := range *queryPending {
+			emitFunc()
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+const parallelTestQueries = `
+queries:
+- name: Nodes
+  resource:
+    name: ACI
+    attributes:
+    - name: aci.entity
+      value: Node
+  scope:
+    name: test
+  rules:
+    query: /nodes
+    select: imdata/*
+    forEach:
+      queryParameters:
+      - name: node
+        valueFrom: name
+      resourceAttributes:
+      - name: aci.node.name
+        valueFrom: =params["node"]
+      query: /psus/${node}
+      select: imdata/*
+      forEach:
+        emitMetric:
+        - name: psu.power
+          type: gauge
+          valueFrom: power
+          itemAttributes:
+          - name: psu
+            valueFrom: id
+- name: Fabric
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /fabric
+    emitMetric:
+    - name: fabric.health
+      type: gauge
+      valueFrom: health
+`
+
+type metricSink struct {
+	mutex  sync.Mutex
+	points []string
+}
+
+func (s *metricSink) consume(_ context.Context, metrics pmetric.Metrics) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := 0; i < metrics.ResourceMetrics().Len(); i++ {
+		rm := metrics.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				m := sm.Metrics().At(k)
+				dps := m.Gauge().DataPoints()
+				for l := 0; l < dps.Len(); l++ {
+					s.points = append(s.points, fmt.Sprintf("%s %v %v %v", m.Name(), rm.Resource().Attributes().AsRaw(), dps.At(l).Attributes().AsRaw(), dps.At(l).DoubleValue()))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func parallelTestClient() *fakeClient {
+	client := &fakeClient{
+		responses: map[string]string{
+			"/fabric": `{"health":"98"}`,
+			"/nodes":  `{"imdata":[{"name":"leaf1"},{"name":"leaf2"},{"name":"leaf3"},{"name":"spine1"}]}`,
+		},
+	}
+	for _, node := range []string{"leaf1", "leaf2", "leaf3", "spine1"} {
+		client.responses["/psus/"+node] = fmt.Sprintf(`{"imdata":[{"id":"%s-1","power":"%d"},{"id":"%s-2","power":"%d"}]}`, node, len(node)*10, node, len(node)*20)
+	}
+	return client
+}
+
+func runParallelTestScrape(t *testing.T, queryConcurrency int, forEachConcurrency int) []string {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(parallelTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	config.MaxConcurrency = queryConcurrency
+	config.Queries[0].MaxConcurrency = forEachConcurrency
+
+	sink := &metricSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, parallelTestClient(), emitter, config, 60, nil)
+
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+	return sink.points
+}
+
+func TestParallelScrapeMatchesSerial(t *testing.T) {
+	serial := runParallelTestScrape(t, 1, 1)
+	if len(serial) != 9 {
+		t.Fatalf("expected: 9 data points != actual: %d - %v", len(serial), serial)
+	}
+
+	for i := 0; i < 10; i++ {
+		parallel := runParallelTestScrape(t, 2, 3)
+		if !reflect.DeepEqual(serial, parallel) {
+			t.Fatalf("parallel output differs\nserial:   %v\nparallel: %v", serial, parallel)
+		}
+	}
+}
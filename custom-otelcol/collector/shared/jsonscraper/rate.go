@@ -0,0 +1,117 @@
+package jsonscraper
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// series of rate and delta metrics not seen for this many intervals are forgotten
+const defaultStaleIntervals = 5
+
+// counterSeries - previous value of a cumulative counter
+type counterSeries struct {
+	value     float64
+	timestamp time.Time
+	expires   time.Time
+}
+
+// counterStore - previous values of rate and delta metrics keyed on metric name, resource
+// and item attributes, kept across scrapes
+type counterStore struct {
+	mutex  sync.Mutex
+	series map[string]*counterSeries
+}
+
+func newCounterStore() *counterStore {
+	return &counterStore{
+		series: map[string]*counterSeries{},
+	}
+}
+
+// update - stores the value and returns the change since the previous value with the seconds
+// between them, ok is false for the first value of the series or a repeated timestamp.
+// Value lower than the previous one is a counter reset, the change is the value itself.
+func (s *counterStore) update(key string, value float64, timestamp time.Time, ttl time.Duration) (change float64, elapsed float64, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, found := s.series[key]
+	current := &counterSeries{
+		value:     value,
+		timestamp: timestamp,
+	}
+	if ttl > 0 {
+		current.expires = time.Now().Add(ttl)
+	}
+	s.series[key] = current
+	if !found {
+		return 0, 0, false
+	}
+	elapsed = timestamp.Sub(previous.timestamp).Seconds()
+	if elapsed <= 0 {
+		return 0, 0, false
+	}
+	change = value - previous.value
+	if change < 0 {
+		change = value
+	}
+	return change, elapsed, true
+}
+
+// evict - forgets series not updated until their expiration, series of scrapes without
+// interval never expire
+func (s *counterStore) evict(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, series := range s.series {
+		if !series.expires.IsZero() && now.After(series.expires) {
+			delete(s.series, key)
+		}
+	}
+}
+
+func (s *counterStore) len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.series)
+}
+
+func (m *MetricEmit) staleIntervals() int {
+	if m.StaleIntervals > 0 {
+		return m.StaleIntervals
+	}
+	return defaultStaleIntervals
+}
+
+// emitCounterChange - emits per second rate as gauge, or delta as sum with delta temporality,
+// of the cumulative counter value, returns false when nothing is emitted, e.g. for the first value of the series
+func (g *Scraper) emitCounterChange(emit *MetricEmit, value float64, timestamp time.Time, emitContext *scraperContext) bool {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		g.logger.Sugar().Errorf("Metric %s has value %v, rate and delta need a number", emit.Name, value)
+		return false
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	interval := emitContext.interval
+	key := emit.Name + "\x01" + attributesKey(emitContext.getRsrcAttrs()) + "\x01" + attributesKey(emitContext.getItemAttrs())
+	ttl := time.Duration(emit.staleIntervals()*interval) * time.Second
+
+	change, elapsed, ok := g.counters.update(key, value, timestamp, ttl)
+	if !ok {
+		return false
+	}
+
+	derived := *emit
+	if emit.Type == Rate {
+		derived.Type = Gauge
+		change = change / elapsed
+	} else {
+		derived.Type = Sum
+		derived.Temporality = Delta
+	}
+	// the data point covers the time since the previous value
+	g.emitter.EmitMetrics(&derived, change, timestamp, emitContext, int(math.Round(elapsed)))
+	return true
+}
@@ -0,0 +1,142 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+const rateTestQueries = `
+queries:
+- name: Interfaces
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /counters
+    select: /imdata/*
+    forEach:
+      itemAttributes:
+      - name: interface
+        valueFrom: id
+      emitMetric:
+      - name: interface.bytes.rate
+        type: rate
+        unit: By/s
+        valueFrom: bytes
+        timestampFrom: ts
+        timestampFormat: epoch_s
+      - name: interface.bytes.delta
+        type: delta
+        unit: By
+        valueFrom: bytes
+        timestampFrom: ts
+        timestampFormat: epoch_s
+`
+
+func TestRateAndDelta(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(rateTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{responses: map[string]string{}}
+
+	points := map[string]float64{}
+	metricConsumer, err := consumer.NewMetrics(func(_ context.Context, metrics pmetric.Metrics) error {
+		ms := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+		for i := 0; i < ms.Len(); i++ {
+			m := ms.At(i)
+			var dps pmetric.NumberDataPointSlice
+			switch m.Type() {
+			case pmetric.MetricTypeGauge:
+				dps = m.Gauge().DataPoints()
+			case pmetric.MetricTypeSum:
+				if m.Sum().AggregationTemporality() != pmetric.AggregationTemporalityDelta {
+					t.Fatalf("expected delta temporality of %s", m.Name())
+				}
+				dps = m.Sum().DataPoints()
+			default:
+				t.Fatalf("unexpected type %v of %s", m.Type(), m.Name())
+			}
+			for j := 0; j < dps.Len(); j++ {
+				iface, _ := dps.At(j).Attributes().Get("interface")
+				points[m.Name()+" "+iface.Str()] = dps.At(j).DoubleValue()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+
+	scrapes := []struct {
+		response string
+		expected map[string]float64
+	}{
+		{
+			// first values only set up the series
+			response: `{"imdata":[{"id":"eth1/1","bytes":"1000","ts":"1000"},{"id":"eth1/2","bytes":"50","ts":"1000"}]}`,
+			expected: map[string]float64{},
+		},
+		{
+			response: `{"imdata":[{"id":"eth1/1","bytes":"1600","ts":"1060"},{"id":"eth1/2","bytes":"50","ts":"1060"}]}`,
+			expected: map[string]float64{
+				"interface.bytes.rate eth1/1":  10,
+				"interface.bytes.delta eth1/1": 600,
+				"interface.bytes.rate eth1/2":  0,
+				"interface.bytes.delta eth1/2": 0,
+			},
+		},
+		{
+			// eth1/1 counter reset
+			response: `{"imdata":[{"id":"eth1/1","bytes":"300","ts":"1120"},{"id":"eth1/2","bytes":"170","ts":"1120"}]}`,
+			expected: map[string]float64{
+				"interface.bytes.rate eth1/1":  5,
+				"interface.bytes.delta eth1/1": 300,
+				"interface.bytes.rate eth1/2":  2,
+				"interface.bytes.delta eth1/2": 120,
+			},
+		},
+	}
+
+	for i, scrape := range scrapes {
+		client.responses["/counters"] = scrape.response
+		points = map[string]float64{}
+		err = scraper.scrape(context.Background(), config.Queries, 60)
+		if err != nil {
+			t.Fatalf("Scrape %d failed - %v", i, err)
+		}
+		if fmt.Sprint(points) != fmt.Sprint(scrape.expected) {
+			t.Fatalf("scrape %d expected: %v != actual: %v", i, scrape.expected, points)
+		}
+	}
+}
+
+func TestCounterStoreEviction(t *testing.T) {
+	store := newCounterStore()
+	now := time.Now()
+	store.update("short", 1, now, time.Minute)
+	store.update("long", 1, now, time.Hour)
+	store.update("noInterval", 1, now, 0)
+
+	store.evict(now.Add(2 * time.Minute))
+	if store.len() != 2 {
+		t.Fatalf("expected: 2 series != actual: %d", store.len())
+	}
+	if _, _, ok := store.update("short", 2, now.Add(3*time.Minute), time.Minute); ok {
+		t.Fatalf("expected evicted series to start again")
+	}
+	if change, elapsed, ok := store.update("long", 3, now.Add(3*time.Minute), time.Hour); !ok || change != 2 || elapsed != 180 {
+		t.Fatalf("expected: change 2 in 180s != actual: %v in %vs (%t)", change, elapsed, ok)
+	}
+}
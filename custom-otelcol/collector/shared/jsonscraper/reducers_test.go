@@ -0,0 +1,127 @@
+package jsonscraper
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+)
+
+const reducersTestQueries = `
+queries:
+- name: Nodes
+  resource:
+    name: ACI
+  scope:
+    name: test
+  maxConcurrency: 3
+  rules:
+    query: /nodes
+    reducers:
+    - power
+    - order
+    select: imdata/*
+    forEach:
+      queryParameters:
+      - name: node
+        valueFrom: name
+      query: /psus/${node}
+      select: imdata/*
+      forEach:
+        reducerMaps:
+        - name: power
+          valueFrom: =double(jqs("power"))
+          groupBy: '=params["node"].startsWith("leaf") ? "leaf" : "spine"'
+        - name: order
+          valueFrom: =jqs("id")
+    emitMetric:
+    - name: power.total
+      type: gauge
+      valueFrom: =reducerMap("power").sumReducer()
+    - name: power.max
+      type: gauge
+      valueFrom: =reducerMap("power").maxReducer()
+    - name: power.ordered
+      type: gauge
+      valueFrom: '=reducerMap("order")[0] == "leaf1-1" && reducerMap("order")[3] == "leaf2-2" && reducerMap("order").lastReducer() == "spine1-2" ? 1.0 : 0.0'
+    - name: power.roles
+      type: gauge
+      valueFrom: =double(reducerGroups("power").size())
+    - name: power.role
+      type: gauge
+      forEachGroup: power
+      groupAttribute: role
+      valueFrom: =reducerMap("power").sumReducer()
+- name: Fabric
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /fabric
+    reducers:
+    - power
+    reducerMaps:
+    - name: power
+      valueFrom: =double(jqs("health"))
+    emitMetric:
+    - name: power.fabric
+      type: gauge
+      valueFrom: =reducerMap("power").sumReducer()
+`
+
+func TestReducersScopedToRule(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(reducersTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	config.MaxConcurrency = 2
+
+	expected := []string{
+		"power.fabric map[] map[] 98",
+		"power.max map[] map[] 120",
+		"power.ordered map[] map[] 1",
+		"power.role map[] map[role:leaf] 450",
+		"power.role map[] map[role:spine] 180",
+		"power.roles map[] map[] 2",
+		"power.total map[] map[] 630",
+	}
+	for i := 0; i < 10; i++ {
+		sink := &metricSink{}
+		metricConsumer, err := consumer.NewMetrics(sink.consume)
+		if err != nil {
+			t.Fatalf("Cannot create consumer - %v", err)
+		}
+		logger := zap.NewNop()
+		emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+		scraper := NewScraper("test", logger, parallelTestClient(), emitter, config, 60, nil)
+
+		// both queries scrape in parallel, each several times at once, with the same reducer name
+		errs := make(chan error, 3)
+		for j := 0; j < 3; j++ {
+			go func() {
+				errs <- scraper.scrape(context.Background(), config.Queries, 60)
+			}()
+		}
+		for j := 0; j < 3; j++ {
+			if err := <-errs; err != nil {
+				t.Fatalf("Scrape failed - %v", err)
+			}
+		}
+
+		sort.Strings(sink.points)
+		if len(sink.points) != 3*len(expected) {
+			t.Fatalf("expected: %d data points != actual: %d - %v", 3*len(expected), len(sink.points), sink.points)
+		}
+		for j, point := range expected {
+			for k := 0; k < 3; k++ {
+				if actual := sink.points[3*j+k]; actual != point {
+					t.Fatalf("expected: %s != actual: %s\n%v", point, actual, sink.points)
+				}
+			}
+		}
+	}
+}
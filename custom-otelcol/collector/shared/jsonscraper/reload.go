@@ -0,0 +1,134 @@
+package jsonscraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Reload - replaces queries and settings of the running scraper between scrapes. Ticking of the old
+// queries stops, running scrapes finish with the old config, and the new config is scheduled from now.
+// Expression environment, context DB, response cache and previous values of counters are kept.
+// migrate, if not nil, runs while no scrape runs, e.g. to change the schema of the context DB.
+// The old config keeps running when the new one cannot be scheduled or migrate fails.
+func (g *Scraper) Reload(config Config, migrate func() error) error {
+	schedules, err := g.buildSchedules(&config)
+	if err != nil {
+		return fmt.Errorf("cannot schedule reloaded queries of scrapper %s - %v", g.name, err)
+	}
+
+	g.lifecycle.reload.Lock()
+	defer g.lifecycle.reload.Unlock()
+
+	if !g.lifecycle.pause() {
+		return fmt.Errorf("scrapper %s is not running", g.name)
+	}
+	if migrate != nil {
+		if err := migrate(); err != nil {
+			g.resumeTicking()
+			return fmt.Errorf("reload of scrapper %s rejected - %v", g.name, err)
+		}
+	}
+	g.config = config
+	started := g.lifecycle.startTicking(func(ctx context.Context, ticking context.Context) {
+		g.startSchedules(ctx, ticking, schedules)
+	})
+	if !started {
+		return fmt.Errorf("scrapper %s stopped during reload", g.name)
+	}
+	g.logger.Sugar().Infof("Scrapper %s reloaded with %d queries", g.name, len(config.Queries))
+	return nil
+}
+
+// resumeTicking - schedules the current config again after a rejected reload
+func (g *Scraper) resumeTicking() {
+	schedules, err := g.buildSchedules(&g.config)
+	if err != nil {
+		g.logger.Sugar().Errorf("Cannot schedule queries of scrapper %s - %v", g.name, err)
+		return
+	}
+	g.lifecycle.startTicking(func(ctx context.Context, ticking context.Context) {
+		g.startSchedules(ctx, ticking, schedules)
+	})
+}
+
+// FileWatcher - polls files for changes of their content, e.g. query files and table schemas
+type FileWatcher struct {
+	files  []string
+	hashes map[string][sha256.Size]byte
+	logger *zap.Logger
+}
+
+func NewFileWatcher(logger *zap.Logger, files ...string) *FileWatcher {
+	w := &FileWatcher{
+		files:  files,
+		hashes: map[string][sha256.Size]byte{},
+		logger: logger,
+	}
+	w.Changed()
+	return w
+}
+
+// Add - starts watching files which are not watched yet
+func (w *FileWatcher) Add(files ...string) {
+	watched := map[string]bool{}
+	for _, file := range w.files {
+		watched[file] = true
+	}
+	for _, file := range files {
+		if watched[file] {
+			continue
+		}
+		watched[file] = true
+		w.files = append(w.files, file)
+		if content, err := os.ReadFile(file); err == nil {
+			w.hashes[file] = sha256.Sum256(content)
+		}
+	}
+}
+
+// Changed - returns files whose content changed since the previous call, files which cannot
+// be read are skipped until they are readable again
+func (w *FileWatcher) Changed() []string {
+	changed := []string{}
+	for _, file := range w.files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			w.logger.Sugar().Warnf("Cannot read watched file %s - %v", file, err)
+			continue
+		}
+		hash := sha256.Sum256(content)
+		if previous, ok := w.hashes[file]; ok && previous == hash {
+			continue
+		}
+		w.hashes[file] = hash
+		changed = append(changed, file)
+	}
+	return changed
+}
+
+// Watch - calls reload with changed files every interval until ctx is done. A rejected reload is
+// logged and not repeated until the files change again.
+func (w *FileWatcher) Watch(ctx context.Context, interval time.Duration, reload func(changed []string) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed := w.Changed()
+		if len(changed) == 0 {
+			continue
+		}
+		w.logger.Sugar().Infof("Reloading config, changed files %v", changed)
+		if err := reload(changed); err != nil {
+			w.logger.Sugar().Errorf("Reload rejected, the previous config keeps running - %v", err)
+		}
+	}
+}
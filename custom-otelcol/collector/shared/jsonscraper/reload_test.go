@@ -0,0 +1,156 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// urlClient - remembers requested urls, requests do not return before released
+type urlClient struct {
+	release chan struct{}
+	mutex   sync.Mutex
+	urls    []string
+}
+
+func (c *urlClient) Login(ctx context.Context) error {
+	return nil
+}
+
+func (c *urlClient) Logout(ctx context.Context) error {
+	return nil
+}
+
+func (c *urlClient) DoRequest(ctx context.Context, method string, url string, payload *string) (string, error) {
+	c.mutex.Lock()
+	c.urls = append(c.urls, url)
+	c.mutex.Unlock()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-c.release:
+		return `{}`, nil
+	}
+}
+
+func (c *urlClient) requested(url string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, u := range c.urls {
+		if u == url {
+			return true
+		}
+	}
+	return false
+}
+
+func reloadTestConfig(t *testing.T, query string) Config {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(`
+queries:
+- name: Reloaded
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: ` + query + `
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	return config
+}
+
+func TestReload(t *testing.T) {
+	client := &urlClient{release: make(chan struct{})}
+	scraper := newTestScraper(t, client)
+	scraper.config = reloadTestConfig(t, "/old")
+
+	if err := scraper.Reload(reloadTestConfig(t, "/new"), nil); err == nil {
+		t.Fatalf("expected reload of scraper which does not run to fail")
+	}
+
+	scraper.Run()
+	defer scraper.Stop(context.Background())
+	waitFor(t, "old query in flight", func() bool { return client.requested("/old") })
+
+	invalid := reloadTestConfig(t, "/new")
+	invalid.Queries[0].Cron = "not a cron"
+	if err := scraper.Reload(invalid, nil); err == nil {
+		t.Fatalf("expected reload with invalid cron to fail")
+	}
+
+	// migration runs after the running scrape finished, the old config stays when it fails
+	reloaded := make(chan error)
+	go func() {
+		reloaded <- scraper.Reload(reloadTestConfig(t, "/new"), func() error {
+			select {
+			case <-client.release:
+			default:
+				t.Errorf("migrate runs while the old query is in flight")
+			}
+			return fmt.Errorf("migration failed")
+		})
+	}()
+	select {
+	case err := <-reloaded:
+		t.Fatalf("reload did not wait for the running scrape - %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(client.release)
+	if err := <-reloaded; err == nil {
+		t.Fatalf("expected reload with failed migration to fail")
+	}
+	if query := scraper.config.Queries[0].Rules.Query; query != "/old" {
+		t.Fatalf("expected old query to stay after failed migration, got %s", query)
+	}
+
+	migrated := false
+	if err := scraper.Reload(reloadTestConfig(t, "/new"), func() error {
+		migrated = true
+		return nil
+	}); err != nil {
+		t.Fatalf("Reload failed - %v", err)
+	}
+	if !migrated {
+		t.Fatalf("expected migration to run during reload")
+	}
+	waitFor(t, "new query", func() bool { return client.requested("/new") })
+}
+
+func TestFileWatcher(t *testing.T) {
+	dir := t.TempDir()
+	queries := filepath.Join(dir, "queries.yaml")
+	schema := filepath.Join(dir, "schema.yaml")
+	os.WriteFile(queries, []byte("queries: []"), 0o644)
+	os.WriteFile(schema, []byte("schemas: []"), 0o644)
+
+	watcher := NewFileWatcher(zap.NewNop(), queries, schema)
+	if changed := watcher.Changed(); len(changed) != 0 {
+		t.Fatalf("expected no change, got %v", changed)
+	}
+
+	os.WriteFile(schema, []byte("schemas:\n- name: nodes"), 0o644)
+	if changed := watcher.Changed(); strings.Join(changed, ",") != schema {
+		t.Fatalf("expected: %s != actual: %v", schema, changed)
+	}
+
+	// a file being replaced is skipped until it is readable again
+	os.Remove(queries)
+	if changed := watcher.Changed(); len(changed) != 0 {
+		t.Fatalf("expected no change, got %v", changed)
+	}
+	os.WriteFile(queries, []byte("queries: []"), 0o644)
+	if changed := watcher.Changed(); len(changed) != 0 {
+		t.Fatalf("expected no change of the same content, got %v", changed)
+	}
+}
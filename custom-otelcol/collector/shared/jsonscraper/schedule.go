@@ -0,0 +1,152 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// querySchedule - queries of one scraper sharing the same interval or cron expression
+type querySchedule struct {
+	key      string
+	interval time.Duration
+	cron     *cronSchedule
+	jitter   time.Duration // max random delay of each run
+	queries  []*Query
+}
+
+// next - nominal time of the run following the one at the given time, without jitter
+func (s *querySchedule) next(after time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.next(after)
+	}
+	return after.Add(s.interval)
+}
+
+// period - time between two runs, used for start timestamps of emitted metrics
+func (s *querySchedule) period(at time.Time) time.Duration {
+	if s.cron != nil {
+		next := s.cron.next(at)
+		return s.cron.next(next).Sub(next)
+	}
+	return s.interval
+}
+
+func (s *querySchedule) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
+
+// validateSchedule - checks interval, cron and jitter of the query
+func (q *Query) validateSchedule() error {
+	if q.Interval < 0 || q.Jitter < 0 {
+		return fmt.Errorf("query %s: interval and jitter must not be negative", q.Name)
+	}
+	if q.Cron == "" {
+		return nil
+	}
+	if q.Interval > 0 {
+		return fmt.Errorf("query %s: only one of interval and cron can be set", q.Name)
+	}
+	cron, err := parseCron(q.Cron)
+	if err != nil {
+		return fmt.Errorf("query %s: %v", q.Name, err)
+	}
+	if cron.next(time.Now()).IsZero() {
+		return fmt.Errorf("query %s: cron expression %s never fires", q.Name, q.Cron)
+	}
+	return nil
+}
+
+// buildSchedules - groups queries of the config by their schedule, queries without own schedule
+// run with the scraper's interval. Schedules are kept in the order of their first query.
+func (g *Scraper) buildSchedules(config *Config) ([]*querySchedule, error) {
+	schedules := []*querySchedule{}
+	byKey := map[string]*querySchedule{}
+
+	for _, q := range config.Queries {
+		if err := q.validateSchedule(); err != nil {
+			return nil, err
+		}
+
+		jitter := config.Jitter
+		if q.Jitter > 0 {
+			jitter = q.Jitter
+		}
+
+		var key string
+		var interval time.Duration
+		var cron *cronSchedule
+		switch {
+		case q.Cron != "":
+			key = "cron " + q.Cron
+			cron, _ = parseCron(q.Cron)
+		case q.Interval > 0:
+			key = fmt.Sprintf("every %ds", q.Interval)
+			interval = time.Duration(q.Interval) * time.Second
+		default:
+			if g.interval <= 0 {
+				return nil, fmt.Errorf("query %s: no interval or cron and scraper interval is not set", q.Name)
+			}
+			key = fmt.Sprintf("every %ds", g.interval)
+			interval = time.Duration(g.interval) * time.Second
+		}
+
+		sched, ok := byKey[key]
+		if !ok {
+			sched = &querySchedule{
+				key:      key,
+				interval: interval,
+				cron:     cron,
+			}
+			byKey[key] = sched
+			schedules = append(schedules, sched)
+		}
+		if time.Duration(jitter)*time.Second > sched.jitter {
+			sched.jitter = time.Duration(jitter) * time.Second
+		}
+		sched.queries = append(sched.queries, q)
+	}
+
+	return schedules, nil
+}
+
+// firstRun - interval schedules are staggered over their interval so the scraper
+// does not hit the service with all queries at once, cron schedules wait for their time
+func (s *querySchedule) firstRun(now time.Time, index int, count int) time.Time {
+	if s.cron != nil {
+		return s.cron.next(now)
+	}
+	if count <= 1 {
+		return now
+	}
+	return now.Add(s.interval * time.Duration(index) / time.Duration(count))
+}
+
+// runSchedule - ticks the schedule's runner until ticking is done, scrapes run with ctx
+func (g *Scraper) runSchedule(ctx context.Context, ticking context.Context, sched *querySchedule, runner *scrapeRunner, index int, count int) {
+	next := sched.firstRun(time.Now(), index, count)
+	g.logger.Sugar().Infof("Scrapper %s runs %d queries %s, first run at %v", g.name, len(sched.queries), sched.key, next)
+
+	for {
+		timer := time.NewTimer(time.Until(next) + sched.randomJitter())
+		select {
+		case <-ticking.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		runner.tick(ctx)
+
+		// runs missed while the collector was suspended are not caught up
+		now := time.Now()
+		next = sched.next(next)
+		for !next.After(now) {
+			next = sched.next(next)
+		}
+	}
+}
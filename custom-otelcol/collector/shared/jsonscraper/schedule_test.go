@@ -0,0 +1,95 @@
+package jsonscraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, 2, 28, 23, 58, 30, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 2, 28, 23, 59, 0, 0, time.UTC)},
+		{"*/5 * * * *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"15 8-10 * * *", time.Date(2024, 2, 29, 8, 15, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 6 * * 1-5", time.Date(2024, 2, 29, 6, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, // Friday or the 13th
+		{"@hourly", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		cron, err := parseCron(test.spec)
+		if err != nil {
+			t.Fatalf("Cannot parse %s - %v", test.spec, err)
+		}
+		next := cron.next(from)
+		if !next.Equal(test.expected) {
+			t.Errorf("%s: expected: %v != actual: %v", test.spec, test.expected, next)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+
+	q := &Query{Name: "never", Cron: "0 0 30 2 *"}
+	if err := q.validateSchedule(); err == nil {
+		t.Errorf("expected error for cron which never fires")
+	}
+	q = &Query{Name: "both", Cron: "* * * * *", Interval: 30}
+	if err := q.validateSchedule(); err == nil {
+		t.Errorf("expected error for both interval and cron")
+	}
+}
+
+func TestBuildSchedules(t *testing.T) {
+	scraper := newTestScraper(t, &fakeClient{})
+	scraper.config.Jitter = 2
+	scraper.config.Queries = []*Query{
+		{Name: "faults", Interval: 30},
+		{Name: "health"},
+		{Name: "inventory", Cron: "@hourly", Jitter: 10},
+		{Name: "more faults", Interval: 30},
+	}
+
+	schedules, err := scraper.buildSchedules(&scraper.config)
+	if err != nil {
+		t.Fatalf("Cannot build schedules - %v", err)
+	}
+	if len(schedules) != 3 {
+		t.Fatalf("expected: 3 schedules != actual: %d", len(schedules))
+	}
+
+	faults := schedules[0]
+	if faults.interval != 30*time.Second || len(faults.queries) != 2 || faults.jitter != 2*time.Second {
+		t.Errorf("unexpected faults schedule %+v", faults)
+	}
+	if schedules[1].interval != 60*time.Second || schedules[1].period(time.Now()) != 60*time.Second {
+		t.Errorf("expected health to run with scraper's interval, got %+v", schedules[1])
+	}
+	inventory := schedules[2]
+	if inventory.cron == nil || inventory.jitter != 10*time.Second || inventory.period(time.Now()) != time.Hour {
+		t.Errorf("unexpected inventory schedule %+v", inventory)
+	}
+
+	now := time.Now()
+	if first := faults.firstRun(now, 0, 3); !first.Equal(now) {
+		t.Errorf("expected first schedule to start immediately, got %v", first.Sub(now))
+	}
+	if first := schedules[1].firstRun(now, 1, 3); first.Sub(now) != 20*time.Second {
+		t.Errorf("expected: 20s stagger != actual: %v", first.Sub(now))
+	}
+	for i := 0; i < 100; i++ {
+		if jitter := inventory.randomJitter(); jitter < 0 || jitter >= 10*time.Second {
+			t.Fatalf("jitter %v out of range", jitter)
+		}
+	}
+}
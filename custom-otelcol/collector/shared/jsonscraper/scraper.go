@@ -2,11 +2,13 @@ package jsonscraper
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/antchfx/jsonquery"
@@ -16,12 +18,13 @@ import (
 )
 
 type ScraperClient interface {
+	// ctx is cancelled when the scrape times out or the scraper stops
 	// method = GET, POST
 	// url is full URL https://host:port/path?query
 	// payload is a body for POST method
-	Login() error
-	Logout() error
-	DoRequest(method string, url string, payload *string) (string, error)
+	Login(ctx context.Context) error
+	Logout(ctx context.Context) error
+	DoRequest(ctx context.Context, method string, url string, payload *string) (string, error)
 }
 
 type Scraper struct {
@@ -33,6 +36,12 @@ type Scraper struct {
 	emitter        Emitter
 	db             *contextdb.ContextDb
 	expr           *expr.ExpressionEnvironment
+	skippedTicks   *atomic.Int64
+	lifecycle      *scraperLifecycle
+	cache          *ResponseCache
+	trace          func(expr string, value any, err error) // called after each expression evaluation, nil means no tracing
+	telemetry      *scraperTelemetry                       // internal metrics, noop until SetMeterProvider
+	counters       *counterStore                           // previous values of rate and delta metrics
 }
 
 func NewScraper(name string, logger *zap.Logger, scrapperClient ScraperClient, emitter Emitter, config Config, interval int, db *contextdb.ContextDb) Scraper {
@@ -51,9 +60,34 @@ func NewScraper(name string, logger *zap.Logger, scrapperClient ScraperClient, e
 		name:           name,
 		emitter:        emitter,
 		db:             db,
+		skippedTicks:   &atomic.Int64{},
+		lifecycle:      &scraperLifecycle{},
+		cache:          NewResponseCache(),
+		telemetry:      newNoopTelemetry(name),
+		counters:       newCounterStore(),
 	}
 }
 
+// SkippedTicks - number of ticks when the scrape did not start because the previous one was still running
+func (g *Scraper) SkippedTicks() int64 {
+	return g.skippedTicks.Load()
+}
+
+// ScrapeOnce - runs all queries once outside of the schedule, e.g. for offline tools
+func (g *Scraper) ScrapeOnce(ctx context.Context) error {
+	return g.scrape(ctx, g.config.Queries, g.interval)
+}
+
+// SetClock - replaces the clock of now() in expressions, e.g. to replay fixtures at the time of recording
+func (g *Scraper) SetClock(clock func() time.Time) {
+	g.expr.SetClock(clock)
+}
+
+// SetEvaluationTrace - sets function called with the result of each expression evaluation
+func (g *Scraper) SetEvaluationTrace(trace func(expr string, value any, err error)) {
+	g.trace = trace
+}
+
 func (g *Scraper) Run() {
 
 	g.logger.Info("Starting scrapper...\n")
@@ -68,31 +102,45 @@ func (g *Scraper) Run() {
 		return
 	*/
 
-	ticker := time.NewTicker(time.Duration(g.interval) * time.Second)
-	quit := make(chan struct{})
-	go func() {
-		err := g.scrape()
-		if err != nil {
-			g.logger.Sugar().Errorf("Error scrapping %s: ", g.name, zap.Error(err))
-		}
-		for {
-			select {
-			case <-ticker.C:
-				go func() {
-					err := g.scrape()
-					if err != nil {
-						g.logger.Sugar().Errorf("Error scrapping %s: ", g.name, zap.Error(err))
-					}
-				}()
-			case <-quit:
-				ticker.Stop()
-				return
-			}
-		}
-	}()
+	schedules, err := g.buildSchedules(&g.config)
+	if err != nil {
+		g.logger.Sugar().Errorf("Cannot schedule queries of scrapper %s - %v", g.name, err)
+		return
+	}
+
+	g.lifecycle.begin()
+	g.lifecycle.startTicking(func(ctx context.Context, ticking context.Context) {
+		g.startSchedules(ctx, ticking, schedules)
+	})
+}
+
+// startSchedules - ticks the schedules until ticking is done, scrapes run with ctx
+func (g *Scraper) startSchedules(ctx context.Context, ticking context.Context, schedules []*querySchedule) {
+	for i, sched := range schedules {
+		sched := sched
+		// runner per schedule, a slow scrape does not hold back or cancel queries with other cadence
+		runner := newScrapeRunner(g.name+" "+sched.key, g.logger, g.config.OverlapPolicy, time.Duration(g.config.ScrapeTimeout)*time.Second, g.skippedTicks, func(ctx context.Context) error {
+			return g.scrape(ctx, sched.queries, int(sched.period(time.Now())/time.Second))
+		})
+		runner.wg = &g.lifecycle.wg
+
+		g.lifecycle.wg.Add(1)
+		go func(i int) {
+			defer g.lifecycle.wg.Done()
+			g.runSchedule(ctx, ticking, sched, runner, i, len(schedules))
+		}(i)
+	}
+}
+
+// Stop - stops the ticker, cancels running scrapes and waits until all in-flight work ends
+// or ctx expires
+func (g *Scraper) Stop(ctx context.Context) error {
+	g.logger.Sugar().Infof("Stopping scrapper %s...", g.name)
+	return g.lifecycle.end(ctx)
 }
 
-func (g *Scraper) scrape() error {
+// scrape - runs the queries once, interval is the seconds between two scrapes of these queries
+func (g *Scraper) scrape(ctx context.Context, queries []*Query, interval int) error {
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -100,23 +148,44 @@ func (g *Scraper) scrape() error {
 		}
 	}()
 
-	err := g.scrapperClient.Login()
+	err := g.scrapperClient.Login(ctx)
 	if err != nil {
 		g.logger.Sugar().Infof("Not logged in - %v", err)
 		return err
 	}
 
-	for _, q := range g.config.Queries {
-		g.scrapeOneQuery(q)
-	}
-	g.scrapperClient.Logout()
+	g.counters.evict(time.Now())
 
-	return nil
+	batch := g.emitter.newBatch(g.config.FlushSize)
+	g.scrapeQueries(ctx, queries, interval, batch)
+	g.scrapperClient.Logout(ctx)
+
+	return batch.finish()
 }
 
-func (g *Scraper) scrapeOneQuery(query *Query) error {
+// recoverBranch - keeps panic in a parallel branch from crashing the collector
+func (g *Scraper) recoverBranch() {
+	if r := recover(); r != nil {
+		g.logger.Sugar().Errorf("***** Recovered in scrape branch: %v\n%s\n", r, string(debug.Stack()))
+	}
+}
+
+func (g *Scraper) scrapeOneQuery(ctx context.Context, query *Query, interval int, batch *emitBatch, pending *pendingEmits) error {
+
+	start := time.Now()
+	// rules with onError abort stop the query by cancelling its context
+	queryCtx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
 
 	scrapeContext := newScaperContext()
+	scrapeContext.runCtx = queryCtx
+	scrapeContext.abort = abort
+	scrapeContext.interval = interval
+	scrapeContext.batch = batch
+	scrapeContext.pending = pending
+	scrapeContext.slots = newBranchSlots(query.MaxConcurrency)
+	scrapeContext.query = query
+	scrapeContext.rulePath = "rules"
 	scrapeContext.push()
 
 	// memory leak prevention
@@ -125,20 +194,34 @@ func (g *Scraper) scrapeOneQuery(query *Query) error {
 	}()
 
 	for _, attr := range query.Resource.Attributes {
-		scrapeContext.addRsrcAttr(attr.Name, attr.Value)
+		value, err := convertAttribute(attr.Value, attr.Type)
+		if err != nil {
+			g.logger.Sugar().Errorf("Resource attribute %s of query %s - %v", attr.Name, query.Name, err)
+			continue
+		}
+		scrapeContext.addRsrcAttr(attr.Name, value)
 	}
 	scrapeContext.setScope(query.Scope)
 
 	err := g.runRuleNew(&query.Rules, nil, &scrapeContext)
+	if cause := context.Cause(queryCtx); err == nil && cause != nil {
+		err = cause
+	}
 	if err != nil {
 		g.logger.Sugar().Errorf("Error scrapping query %s - %v", query.Name, err)
 	}
+	g.telemetry.recordScrape(ctx, query, start, err == nil && scrapeContext.failures.Load() == 0)
 
 	return nil
 
 }
 
 func (g *Scraper) runRuleNew(rule *Rule, doc *jsonquery.Node, scContext *scraperContext) error {
+	// scrape cancelled, timed out, or aborted
+	if err := scContext.runCtx.Err(); err != nil {
+		return context.Cause(scContext.runCtx)
+	}
+
 	scContext.push()
 	defer func() {
 		scContext.pop()
@@ -152,24 +235,43 @@ func (g *Scraper) runRuleNew(rule *Rule, doc *jsonquery.Node, scContext *scraper
 	g.evaluateParameters(rule.QueryParameters, doc, scContext)
 	//}
 
+	if rule.When != "" {
+		passed, err := g.evaluateCondition(rule.When, doc, scContext)
+		if err != nil {
+			g.logger.Sugar().Errorf("Cannot evaluate when condition %s, skipping the rule - %v", rule.When, err)
+			return nil
+		}
+		if !passed {
+			g.logger.Sugar().Debugf("Rule skipped, when condition %s is false", rule.When)
+			return nil
+		}
+	}
+
+	if passed, err := g.evaluateFilters(rule.Filters, doc, scContext); !passed || err != nil {
+		if err != nil {
+			g.logger.Sugar().Errorf("Error evaluating rule filter, skipping the rule - %v", err)
+		}
+		return nil
+	}
+
 	switch rule.Query {
 	case "":
 		currDoc = doc
 	case "LOOP_ITEM":
 		currDoc = doc
 	default:
-		url := g.fillParams(rule.Query, scContext)
-		g.logger.Sugar().Debugf("QUERY URL: %s", url)
-
-		if rule.QueryPostData == nil {
-			currDoc, err = g.getDataFromService("GET", url, nil)
-		} else {
-			postData := g.fillParams(*(rule.QueryPostData), scContext)
-			currDoc, err = g.getDataFromService("POST", url, &postData)
-		}
+		var url string
+		currDoc, url, err = g.queryService(rule, doc, scContext)
 		if err != nil {
-			g.logger.Sugar().Errorf("Cannot get data from service %s - %v", rule.Query, err)
-			return err
+			scContext.failures.Add(1)
+			if !g.handleError(rule.OnError, url, err, scContext) {
+				return err
+			}
+			currDoc, err = defaultDocument(rule.Default)
+			if err != nil {
+				g.logger.Sugar().Errorf("Cannot use default of rule %s - %v", rule.Query, err)
+				return err
+			}
 		}
 	}
 
@@ -185,9 +287,9 @@ func (g *Scraper) runRuleNew(rule *Rule, doc *jsonquery.Node, scContext *scraper
 	g.evaluateResourceAttributes(rule.ResourceAttributes, currDoc, scContext)
 	g.evaluateItemAttributes(rule.ItemAttributes, currDoc, scContext)
 
-	// setup reducers if any
-	for _, reducer := range rule.Reducers {
-		g.expr.InitReducerMap(reducer)
+	// setup reducers if any, they live as long as this invocation of the rule
+	if len(rule.Reducers) > 0 {
+		scContext.setReducers(expr.NewReducers(scContext.getReducers(), rule.Reducers...))
 	}
 
 	// Select returns an arrays of jsonquery Nodes from current document. It comes together with "ForEach",
@@ -197,36 +299,24 @@ func (g *Scraper) runRuleNew(rule *Rule, doc *jsonquery.Node, scContext *scraper
 
 		list := jsonquery.Find(currDoc, rule.Select)
 		g.logger.Sugar().Debugf("Selected length %d\n%v", len(list), list)
+		g.telemetry.recordItemsSelected(scContext.runCtx, scContext.query, scContext.rulePath, len(list))
 
-		for _, subDoc := range list {
-			err = g.runRuleNew(rule.ForEach, subDoc, scContext)
-			if err != nil {
-				g.logger.Sugar().Errorf("Rule processing failed %v: %v - %v", rule.ForEach, scContext, err)
-			}
-		}
+		g.runForEach(rule, list, scContext, g.forEachConcurrency(rule, scContext.query))
 	}
 
-	// process reducer maps if any
-	for _, rMap := range rule.ReducerMaps {
-		if rMap.Name == "" {
-			continue
-		}
-		if rMap.Value != nil {
-			g.expr.AddValueToReducerMap(rMap.Name, *rMap.Value)
-			continue
-		}
-		if rMap.ValueFrom != nil {
-			valueAny, err := g.evaluateValueFrom(currDoc, *rMap.ValueFrom, scContext)
-			if err != nil {
-				g.logger.Sugar().Errorf("Error evaluating expression for reducer map %s: %s - %v", rMap.Name, *rMap.ValueFrom, err)
-			}
-			// value, ok := valueAny.(float64)
-			// if !ok {
-			// 	g.logger.Sugar().Errorf("Error return type for expression for reducer map %s: %s - expected float64, actual %T", rMap.Name, *rMap.ValueFrom, valueAny)
-			// }
-			g.expr.AddValueToReducerMap(rMap.Name, valueAny)
+	// child rules take their own drill-downs from the same document
+	rulePath := scContext.rulePath
+	for i := range rule.Children {
+		scContext.rulePath = fmt.Sprintf("%s/children[%d]", rulePath, i)
+		err := g.runRuleNew(&rule.Children[i], currDoc, scContext)
+		if err != nil {
+			g.logger.Sugar().Errorf("Child rule processing failed %v: %v - %v", rule.Children[i], scContext, err)
 		}
 	}
+	scContext.rulePath = rulePath
+
+	// process reducer maps if any
+	g.processReducerMaps(rule.ReducerMaps, currDoc, scContext)
 
 	// process metric and logs emits at this level of rule if present
 	if rule.EmitMetric != nil {
@@ -244,8 +334,69 @@ func (g *Scraper) runRuleNew(rule *Rule, doc *jsonquery.Node, scContext *scraper
 	return nil
 }
 
-func (g *Scraper) getDataFromService(method string, uri string, payload *string) (*jsonquery.Node, error) {
-	response, err := g.scrapperClient.DoRequest(method, uri, payload)
+// processReducerMaps - adds values to reducers declared by the rule or its parents
+func (g *Scraper) processReducerMaps(reducerMaps []ReducerMap, doc *jsonquery.Node, scContext *scraperContext) {
+	for _, rMap := range reducerMaps {
+		if rMap.Name == "" {
+			continue
+		}
+		group := ""
+		if rMap.GroupBy != nil {
+			groupAny, err := g.evaluateValueFrom(doc, *rMap.GroupBy, scContext)
+			if err != nil {
+				g.logger.Sugar().Errorf("Error evaluating group of reducer map %s: %s - %v", rMap.Name, *rMap.GroupBy, err)
+				continue
+			}
+			group = g.stringifyVal(groupAny)
+		}
+		var value any
+		switch {
+		case rMap.Value != nil:
+			value = *rMap.Value
+		case rMap.ValueFrom != nil:
+			valueAny, err := g.evaluateValueFrom(doc, *rMap.ValueFrom, scContext)
+			if err != nil {
+				g.logger.Sugar().Errorf("Error evaluating expression for reducer map %s: %s - %v", rMap.Name, *rMap.ValueFrom, err)
+				continue
+			}
+			value = valueAny
+		default:
+			continue
+		}
+		if err := scContext.addToReducer(rMap.Name, group, value); err != nil {
+			g.logger.Sugar().Errorf("Cannot add value to reducer map %s - %v", rMap.Name, err)
+		}
+	}
+}
+
+// queryService - fills the rule's query templates and reads the document from the service,
+// returns the filled URL, or the template when it cannot be filled
+func (g *Scraper) queryService(rule *Rule, doc *jsonquery.Node, scContext *scraperContext) (*jsonquery.Node, string, error) {
+	url, err := g.fillTemplate(rule.Query, doc, scContext)
+	if err != nil {
+		return nil, rule.Query, fmt.Errorf("Cannot build query URL - %v", err)
+	}
+	g.logger.Sugar().Debugf("QUERY URL: %s", url)
+
+	method := "GET"
+	var postData *string
+	if rule.QueryPostData != nil {
+		method = "POST"
+		filledPostData, err := g.fillTemplate(*(rule.QueryPostData), doc, scContext)
+		if err != nil {
+			return nil, url, fmt.Errorf("Cannot build query post data - %v", err)
+		}
+		postData = &filledPostData
+	}
+	currDoc, err := g.getCachedDataFromService(scContext, rule, method, url, postData)
+	if err != nil {
+		return nil, url, fmt.Errorf("Cannot get data from service %s - %v", rule.Query, err)
+	}
+	return currDoc, url, nil
+}
+
+func (g *Scraper) getDataFromService(ctx context.Context, format string, method string, uri string, payload *string) (*jsonquery.Node, error) {
+	response, err := g.scrapperClient.DoRequest(ctx, method, uri, payload)
 	if err != nil {
 		var pld string
 		if payload == nil {
@@ -253,11 +404,11 @@ func (g *Scraper) getDataFromService(method string, uri string, payload *string)
 		} else {
 			pld = *payload
 		}
-		return nil, fmt.Errorf("Error in getting data from service %s, method %s, uri %s, payload %s", g.name, method, uri, pld)
+		return nil, fmt.Errorf("Error in getting data from service %s, method %s, uri %s, payload %s - %v", g.name, method, uri, pld, err)
 	}
-	doc, err := jsonquery.Parse(strings.NewReader(response))
+	doc, err := parseResponse(format, response)
 	if err != nil {
-		return nil, fmt.Errorf("Error in parsing response data from service %s, method %s, uri %s, response %s", g.name, method, uri, response)
+		return nil, fmt.Errorf("Error in parsing %s response data from service %s, method %s, uri %s, response %s - %v", format, g.name, method, uri, response, err)
 	}
 
 	return doc, nil
@@ -265,36 +416,30 @@ func (g *Scraper) getDataFromService(method string, uri string, payload *string)
 
 func (g *Scraper) evaluateResourceAttributes(attrs []Attribute, doc *jsonquery.Node, scContext *scraperContext) error {
 	for _, a := range attrs {
-		if a.Value != "" {
-			scContext.addRsrcAttr(a.Name, a.Value)
-		} else {
-			valueAny, err := g.evaluateValueFrom(doc, a.ValueFrom, scContext)
-			if err != nil {
-				err = fmt.Errorf("Cannot evaluate expr %s - %v", a.ValueFrom, err)
-				g.logger.Sugar().Errorf("%v", err)
-				return err
-			}
-			g.logger.Sugar().Debugf("EVALUATED: %T - %v", valueAny, valueAny)
-			scContext.addRsrcAttr(a.Name, valueAny)
+		a := a
+		valueAny, err := g.evaluateAttribute(&a, doc, scContext)
+		if err != nil {
+			err = fmt.Errorf("Attribute %s - %v", a.Name, err)
+			g.logger.Sugar().Errorf("%v", err)
+			return err
 		}
+		g.logger.Sugar().Debugf("EVALUATED: %T - %v", valueAny, valueAny)
+		scContext.addRsrcAttr(a.Name, valueAny)
 	}
 	return nil
 }
 
 func (g *Scraper) evaluateItemAttributes(attrs []Attribute, doc *jsonquery.Node, scContext *scraperContext) error {
 	for _, a := range attrs {
-		if a.Value != "" {
-			scContext.addItemAttr(a.Name, a.Value)
-		} else {
-			valueAny, err := g.evaluateValueFrom(doc, a.ValueFrom, scContext)
-			if err != nil {
-				err = fmt.Errorf("Cannot evaluate expr %s - %v", a.ValueFrom, err)
-				g.logger.Sugar().Errorf("%v", err)
-				return err
-			}
-			g.logger.Sugar().Debugf("EVALUATED: %T - %v", valueAny, valueAny)
-			scContext.addItemAttr(a.Name, valueAny)
+		a := a
+		valueAny, err := g.evaluateAttribute(&a, doc, scContext)
+		if err != nil {
+			err = fmt.Errorf("Attribute %s - %v", a.Name, err)
+			g.logger.Sugar().Errorf("%v", err)
+			return err
 		}
+		g.logger.Sugar().Debugf("EVALUATED: %T - %v", valueAny, valueAny)
+		scContext.addItemAttr(a.Name, valueAny)
 	}
 	return nil
 }
@@ -335,36 +480,26 @@ func (g *Scraper) processEmitLogs(emitRules []LogEmit, doc *jsonquery.Node, scCo
 			} else {
 				g.evaluateResourceAttributes(emit.ResourceAttributes, doc, scContext)
 				g.evaluateItemAttributes(emit.ItemAttributes, doc, scContext)
-				var message string
-				var serviceNativeSeverity string
-				var timestamp string
-				if emit.MessageFrom != "" {
-					messageAny, err := g.evaluateValueFrom(doc, emit.MessageFrom, scContext)
-					if err != nil {
-						g.logger.Sugar().Errorf("Cannot evaluate expr %s - %v", emit.MessageFrom, err)
-						return
-					}
-					message = fmt.Sprintf("%v", messageAny)
-				}
-				if emit.SeverityFrom != "" {
-					serviceNativeSeverityAny, err := g.evaluateValueFrom(doc, emit.SeverityFrom, scContext)
-					if err != nil {
-						g.logger.Sugar().Errorf("Cannot evaluate expr %s - %v", emit.MessageFrom, err)
-						return
+				value, err := g.evaluateLog(&emit, doc, scContext)
+				if err != nil {
+					if !g.handleError(emit.OnError, "", fmt.Errorf("Log of type %s - %v", emit.LogType, err), scContext) {
+						if scContext.runCtx.Err() != nil {
+							return
+						}
+						continue
 					}
-					serviceNativeSeverity = fmt.Sprintf("%v", serviceNativeSeverityAny)
-				}
-				if emit.TimestampFrom != "" {
-					timestampAny, err := g.evaluateValueFrom(doc, emit.TimestampFrom, scContext)
-					if err != nil {
-						g.logger.Sugar().Errorf("Cannot evaluate expr %s - %v", emit.TimestampFrom, err)
-						return
+					value = &logValue{body: ""}
+					if emit.Default != nil {
+						value.body = yamlToJSON(emit.Default)
 					}
-					timestamp = fmt.Sprintf("%v", timestampAny)
 				}
-				g.logger.Sugar().Debugf("Log emit rules: %v, msg: %s, ctx: %v, consumer: %v", emit, message, scContext, g.emitter.metricConsumer)
+				g.logger.Sugar().Debugf("Log emit rules: %v, body: %v, ctx: %v, consumer: %v", emit, value.body, scContext, g.emitter.logConsumer)
 
-				g.emitter.EmitLogs(&emit, message, serviceNativeSeverity, timestamp, scContext, g.interval)
+				emit := emit
+				g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalLog)
+				scContext.emit(func(emitContext *scraperContext) {
+					g.emitter.EmitLogs(&emit, value, emitContext, emitContext.interval)
+				})
 			}
 		}
 	}
@@ -381,35 +516,141 @@ func (g *Scraper) processEmitMetrics(emitRules []MetricEmit, doc *jsonquery.Node
 
 	if g.emitter.metricConsumer != nil { // This is a metric receiver
 		for _, emit := range emitRules {
+			emit := emit
 			if passed, err := g.evaluateFilters(emit.Filters, doc, scContext); !passed || err != nil {
 				if err != nil {
 					g.logger.Sugar().Errorf("Error evaluating filter - %v", err)
 					continue
 				}
+			} else if emit.ForEachGroup != "" {
+				g.processEmitMetricGroups(&emit, doc, scContext)
+			} else if emit.EmitEach != nil {
+				g.processEmitEach(&emit, doc, scContext)
 			} else {
-				g.evaluateResourceAttributes(emit.ResourceAttributes, doc, scContext)
-				g.evaluateItemAttributes(emit.ItemAttributes, doc, scContext)
-				var value = 0.0
-				valueAny, err := g.evaluateValueFrom(doc, emit.ValueFrom, scContext)
-				if err != nil {
-					g.logger.Sugar().Errorf("Cannot evaluate expr %s - %v", emit.ValueFrom, err)
-					return
-				}
-				valueStr := g.stringifyVal(valueAny)
-				if valueStr == "" {
-					valueStr = "0"
-				}
-				value, err = strconv.ParseFloat(valueStr, 64)
-				if err != nil {
-					g.logger.Sugar().Errorf("Metric value from %s = %s is not number - %v", emit.ValueFrom, valueAny, err)
-					return
+				err = g.processEmitMetric(&emit, doc, scContext)
+				if err != nil && g.handleError(emit.OnError, "", err, scContext) {
+					g.emitDefaultMetric(&emit, scContext)
 				}
-
-				g.logger.Sugar().Debugf("Emitting metric emit: %v, val: %v, ctx: %v, interval: %v", emit, value, scContext, g.interval)
-				g.emitter.EmitMetrics(&emit, value, scContext, g.interval)
 			}
+			// aborted by this or a parallel emit
+			if scContext.runCtx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// processEmitMetricGroups - emits the metric once per group of the forEachGroup reducer, with
+// the group key as item attribute and reducerMap returning values of the group only
+func (g *Scraper) processEmitMetricGroups(emit *MetricEmit, doc *jsonquery.Node, scContext *scraperContext) {
+	reducers := scContext.getReducers()
+	groupAttribute := emit.GroupAttribute
+	if groupAttribute == "" {
+		groupAttribute = "group"
+	}
+	for _, key := range reducers.GroupKeys(emit.ForEachGroup) {
+		scContext.push()
+		scContext.setReducers(reducers.Group(emit.ForEachGroup, key))
+		scContext.addItemAttr(groupAttribute, key)
+		err := g.processEmitMetric(emit, doc, scContext)
+		if err != nil && g.handleError(emit.OnError, "", err, scContext) {
+			g.emitDefaultMetric(emit, scContext)
+		}
+		scContext.pop()
+		if scContext.runCtx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (g *Scraper) processEmitMetric(emit *MetricEmit, doc *jsonquery.Node, scContext *scraperContext) error {
+	g.evaluateResourceAttributes(emit.ResourceAttributes, doc, scContext)
+	g.evaluateItemAttributes(emit.ItemAttributes, doc, scContext)
+	timestamp, err := g.evaluateTimestamp(emit.TimestampFrom, emit.TimestampFormat, emit.Timezone, doc, scContext)
+	if err != nil {
+		return fmt.Errorf("Cannot evaluate timestamp of metric %s - %v", emit.Name, err)
+	}
+	emit, err = g.resolveMetricEmit(emit, doc, scContext)
+	if err != nil {
+		return err
+	}
+
+	switch emit.Type {
+	case Histogram:
+		value, err := g.evaluateHistogram(emit, doc, scContext)
+		if err != nil {
+			return fmt.Errorf("Cannot evaluate histogram %s - %v", emit.Name, err)
 		}
+		g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalMetric)
+		scContext.emit(func(emitContext *scraperContext) {
+			g.emitter.EmitHistogram(emit, value, timestamp, emitContext, emitContext.interval)
+		})
+		return nil
+	case Summary:
+		value, err := g.evaluateSummary(emit, doc, scContext)
+		if err != nil {
+			return fmt.Errorf("Cannot evaluate summary %s - %v", emit.Name, err)
+		}
+		g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalMetric)
+		scContext.emit(func(emitContext *scraperContext) {
+			g.emitter.EmitSummary(emit, value, timestamp, emitContext, emitContext.interval)
+		})
+		return nil
 	}
+
+	var value = 0.0
+	valueAny, err := g.evaluateValueFrom(doc, emit.ValueFrom, scContext)
+	if err != nil {
+		return fmt.Errorf("Cannot evaluate expr %s of metric %s - %v", emit.ValueFrom, emit.Name, err)
+	}
+	valueStr := g.stringifyVal(valueAny)
+	if valueStr == "" {
+		valueStr = "0"
+	}
+	value, err = strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return fmt.Errorf("Metric value from %s = %s is not number - %v", emit.ValueFrom, valueAny, err)
+	}
+	g.emitNumber(emit, value, timestamp, scContext)
+	return nil
+}
+
+// emitNumber - emits gauge or sum value, or the change of rate and delta counter
+func (g *Scraper) emitNumber(emit *MetricEmit, value float64, timestamp time.Time, scContext *scraperContext) {
+	g.logger.Sugar().Debugf("Emitting metric emit: %v, val: %v, ctx: %v, interval: %v", emit, value, scContext, scContext.interval)
+	if emit.Type == Rate || emit.Type == DeltaValue {
+		scContext.emit(func(emitContext *scraperContext) {
+			if g.emitCounterChange(emit, value, timestamp, emitContext) {
+				g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalMetric)
+			}
+		})
+		return
+	}
+	g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalMetric)
+	scContext.emit(func(emitContext *scraperContext) {
+		g.emitter.EmitMetrics(emit, value, timestamp, emitContext, emitContext.interval)
+	})
+}
+
+// emitDefaultMetric - emits metric's default value with the current time, gauge and sum only
+func (g *Scraper) emitDefaultMetric(emit *MetricEmit, scContext *scraperContext) {
+	if emit.Type != Gauge && emit.Type != Sum {
+		g.logger.Sugar().Errorf("Metric %s of type %s has no default value, only gauge and sum have", emit.Name, emit.Type)
+		return
+	}
+	if emit.Name == "" {
+		g.logger.Sugar().Errorf("Metric from %s has no name for its default value", emit.NameFrom)
+		return
+	}
+	value, err := defaultNumber(emit.Default)
+	if err != nil {
+		g.logger.Sugar().Errorf("Cannot use default of metric %s - %v", emit.Name, err)
+		return
+	}
+	g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalMetric)
+	scContext.emit(func(emitContext *scraperContext) {
+		g.emitter.EmitMetrics(emit, value, time.Time{}, emitContext, emitContext.interval)
+	})
 }
 
 func (g *Scraper) processEmitDbRecord(emitRules []DBEmit, doc *jsonquery.Node, scContext *scraperContext) {
@@ -465,6 +706,7 @@ func (g *Scraper) processEmitDbRecord(emitRules []DBEmit, doc *jsonquery.Node, s
 				Data: recordNode,
 			}
 			g.db.InsertOrUpdateRecord(emit.DB, &record)
+			g.telemetry.recordEmitted(scContext.runCtx, scContext.query, signalDbRecord)
 		}
 	}
 
@@ -479,7 +721,7 @@ func (g *Scraper) processEmitDbRecord(emitRules []DBEmit, doc *jsonquery.Node, s
 func (g *Scraper) evaluateFilters(filters []Filter, doc *jsonquery.Node, scContext *scraperContext) (bool, error) {
 	filtersPassed := true
 	for _, f := range filters {
-		isValueAny, err := g.evaluateValueFrom(doc, f.Is, &scraperContext{})
+		isValueAny, err := g.evaluateValueFrom(doc, f.Is, scContext)
 		if err != nil {
 			return false, fmt.Errorf("Cannot evaluate expr %s - %v", f.Is, err)
 		}
@@ -496,49 +738,20 @@ func (g *Scraper) evaluateFilters(filters []Filter, doc *jsonquery.Node, scConte
 	return filtersPassed, nil
 }
 
-func (g *Scraper) fillParams(templ string, scContext *scraperContext) string {
-	result := templ
-	paramsMap := scContext.getParameters()
-
-	for key, value := range paramsMap {
-		placeholder := "${" + key + "}"
-		result = strings.ReplaceAll(result, placeholder, fmt.Sprintf("%v", value))
+// evaluateCondition - evaluates expression which must return bool, leading = is optional
+func (g *Scraper) evaluateCondition(condition string, doc *jsonquery.Node, scContext *scraperContext) (bool, error) {
+	if !strings.HasPrefix(condition, "=") {
+		condition = "=" + condition
 	}
-	return result
-
-	// return g.fillParamsExt(templ, scContext, '{', '}')
-}
-
-func (g *Scraper) fillParamsExt(templ string, scContext *scraperContext, startChar byte, endChar byte) (string, error) {
-
-	paramsMap := scContext.getParameters()
-	result := ""
-	inParam := false
-	paramName := ""
-	for i := 0; i < len(templ); i++ {
-		switch templ[i] {
-		case startChar:
-			inParam = true
-		case endChar:
-			inParam = false
-			value, ok := paramsMap[paramName]
-			if !ok {
-				return "", fmt.Errorf("Missing variable %s for template %s", paramName, templ)
-			}
-			result += fmt.Sprintf("%v", value)
-			paramName = ""
-		case '\\':
-			i++
-			result += string(templ[i])
-		default:
-			if inParam {
-				paramName += string(templ[i])
-			} else {
-				result += string(templ[i])
-			}
-		}
+	valueAny, err := g.evaluateValueFrom(doc, condition, scContext)
+	if err != nil {
+		return false, fmt.Errorf("Cannot evaluate expr %s - %v", condition, err)
 	}
-	return result, nil
+	value, ok := valueAny.(bool)
+	if !ok {
+		return false, fmt.Errorf("Expression evaluated not to bool value %v", valueAny)
+	}
+	return value, nil
 }
 
 func (g *Scraper) evalAttribute(attr *Attribute, doc *jsonquery.Node, scrapperContext *scraperContext) string {
@@ -561,6 +774,15 @@ func (g *Scraper) evalAttribute(attr *Attribute, doc *jsonquery.Node, scrapperCo
 // scrapeContext related struct/logic
 
 func (g *Scraper) evaluateValueFrom(doc *jsonquery.Node, expr string, scrapeContext *scraperContext) (any, error) {
+	value, err := g.evaluateRawValueFrom(doc, expr, scrapeContext)
+	if err == nil && expr[0] != '=' {
+		value = g.stringifyVal(value)
+	}
+	return value, err
+}
+
+// evaluateRawValueFrom - like evaluateValueFrom, but jsonquery results keep their JSON type
+func (g *Scraper) evaluateRawValueFrom(doc *jsonquery.Node, expr string, scrapeContext *scraperContext) (any, error) {
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -577,8 +799,12 @@ func (g *Scraper) evaluateValueFrom(doc *jsonquery.Node, expr string, scrapeCont
 			"resAttr": scrapeContext.getRsrcAttrs(),
 			"params":  scrapeContext.getParameters(),
 		}
-		value, err = g.expr.EvaluateExpressionWithJqDoc(doc, expr[1:], bindings)
+		value, err = g.expr.EvaluateExpressionWithReducers(doc, scrapeContext.getReducers(), expr[1:], bindings)
+		if g.trace != nil {
+			g.trace(expr[1:], value, err)
+		}
 		if err != nil {
+			g.telemetry.recordExpressionError(scrapeContext.runCtx, scrapeContext.query, scrapeContext.rulePath)
 			return "", err
 		}
 		g.logger.Sugar().Debugf("EVALUATE RESULT: %v - %T <= %s", value, value, expr[1:])
@@ -587,8 +813,9 @@ func (g *Scraper) evaluateValueFrom(doc *jsonquery.Node, expr string, scrapeCont
 		if valRef == nil {
 			value = 0
 			err = fmt.Errorf("Cannot evaluate expression %s on %v", expr, doc)
+			g.telemetry.recordExpressionError(scrapeContext.runCtx, scrapeContext.query, scrapeContext.rulePath)
 		} else {
-			value = g.stringifyVal(valRef.Value())
+			value = valRef.Value()
 		}
 	}
 
@@ -8,6 +8,13 @@ func NewStack[T any]() *Stack[T] {
 	return &Stack[T]{nil}
 }
 
+// Clone - returns stack with its own copy of the levels, the values are not copied
+func (stack *Stack[T]) Clone() *Stack[T] {
+	keys := make([]T, len(stack.keys))
+	copy(keys, stack.keys)
+	return &Stack[T]{keys}
+}
+
 func (stack *Stack[T]) Push(key T) {
 	stack.keys = append(stack.keys, key)
 }
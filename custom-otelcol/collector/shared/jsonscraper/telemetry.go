@@ -0,0 +1,172 @@
+package jsonscraper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const telemetryScope = "github.com/chrlic/otelcol-cust/collector/shared/jsonscraper"
+
+// signals counted by jsonscraper_emitted
+const (
+	signalMetric   = "metric"
+	signalLog      = "log"
+	signalDbRecord = "dbRecord"
+)
+
+// scraperTelemetry - internal metrics of the scraper reported through collector's MeterProvider,
+// every measurement carries receiver and query attributes
+type scraperTelemetry struct {
+	receiver         string
+	scrapeDuration   metric.Float64Histogram
+	requests         metric.Int64Counter
+	requestDuration  metric.Float64Histogram
+	expressionErrors metric.Int64Counter
+	itemsSelected    metric.Int64Counter
+	emitted          metric.Int64Counter
+	cacheHits        metric.Int64Counter
+	cacheMisses      metric.Int64Counter
+	mutex            sync.Mutex
+	success          map[string]int64 // query -> 1 when the last scrape succeeded
+}
+
+func newScraperTelemetry(receiver string, meterProvider metric.MeterProvider) (*scraperTelemetry, error) {
+	t := &scraperTelemetry{
+		receiver: receiver,
+		success:  map[string]int64{},
+	}
+	meter := meterProvider.Meter(telemetryScope)
+
+	var err error
+	if t.scrapeDuration, err = meter.Float64Histogram("jsonscraper_scrape_duration",
+		metric.WithDescription("Duration of the scrape of one query"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if t.requests, err = meter.Int64Counter("jsonscraper_http_requests",
+		metric.WithDescription("Requests sent to the service per URL template and outcome")); err != nil {
+		return nil, err
+	}
+	if t.requestDuration, err = meter.Float64Histogram("jsonscraper_http_duration",
+		metric.WithDescription("Latency of requests sent to the service per URL template"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if t.expressionErrors, err = meter.Int64Counter("jsonscraper_expression_errors",
+		metric.WithDescription("Failed expression evaluations per rule")); err != nil {
+		return nil, err
+	}
+	if t.itemsSelected, err = meter.Int64Counter("jsonscraper_items_selected",
+		metric.WithDescription("Items returned by rules' select")); err != nil {
+		return nil, err
+	}
+	if t.emitted, err = meter.Int64Counter("jsonscraper_emitted",
+		metric.WithDescription("Data points, log records and DB records emitted per signal")); err != nil {
+		return nil, err
+	}
+	if t.cacheHits, err = meter.Int64Counter("jsonscraper_cache_hits",
+		metric.WithDescription("Responses of queries with cacheTTL served from the response cache")); err != nil {
+		return nil, err
+	}
+	if t.cacheMisses, err = meter.Int64Counter("jsonscraper_cache_misses",
+		metric.WithDescription("Responses of queries with cacheTTL requested from the service")); err != nil {
+		return nil, err
+	}
+	if _, err = meter.Int64ObservableGauge("jsonscraper_scrape_success",
+		metric.WithDescription("1 when the last scrape of the query succeeded, 0 otherwise"),
+		metric.WithInt64Callback(t.observeSuccess)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *scraperTelemetry) attributes(query *Query, extra ...attribute.KeyValue) metric.MeasurementOption {
+	queryName := ""
+	if query != nil {
+		queryName = query.Name
+	}
+	return metric.WithAttributes(append([]attribute.KeyValue{
+		attribute.String("receiver", t.receiver),
+		attribute.String("query", queryName),
+	}, extra...)...)
+}
+
+// recordScrape - records duration and outcome of the scrape of one query
+func (t *scraperTelemetry) recordScrape(ctx context.Context, query *Query, start time.Time, success bool) {
+	t.scrapeDuration.Record(ctx, time.Since(start).Seconds(), t.attributes(query))
+
+	var value int64
+	if success {
+		value = 1
+	}
+	t.mutex.Lock()
+	t.success[query.Name] = value
+	t.mutex.Unlock()
+}
+
+// recordRequest - urlTemplate is the rule's query before placeholders are filled, so that
+// the number of series does not grow with the scraped objects
+func (t *scraperTelemetry) recordRequest(ctx context.Context, query *Query, urlTemplate string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	t.requests.Add(ctx, 1, t.attributes(query, attribute.String("url", urlTemplate), attribute.String("outcome", outcome)))
+	t.requestDuration.Record(ctx, time.Since(start).Seconds(), t.attributes(query, attribute.String("url", urlTemplate)))
+}
+
+// recordCacheLookup - counts a hit or a miss of the response cache per URL template
+func (t *scraperTelemetry) recordCacheLookup(ctx context.Context, query *Query, urlTemplate string, hit bool) {
+	if hit {
+		t.cacheHits.Add(ctx, 1, t.attributes(query, attribute.String("url", urlTemplate)))
+		return
+	}
+	t.cacheMisses.Add(ctx, 1, t.attributes(query, attribute.String("url", urlTemplate)))
+}
+
+func (t *scraperTelemetry) recordExpressionError(ctx context.Context, query *Query, rulePath string) {
+	t.expressionErrors.Add(ctx, 1, t.attributes(query, attribute.String("rule", rulePath)))
+}
+
+func (t *scraperTelemetry) recordItemsSelected(ctx context.Context, query *Query, rulePath string, count int) {
+	t.itemsSelected.Add(ctx, int64(count), t.attributes(query, attribute.String("rule", rulePath)))
+}
+
+func (t *scraperTelemetry) recordEmitted(ctx context.Context, query *Query, signal string) {
+	t.emitted.Add(ctx, 1, t.attributes(query, attribute.String("signal", signal)))
+}
+
+func (t *scraperTelemetry) observeSuccess(_ context.Context, observer metric.Int64Observer) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for query, value := range t.success {
+		observer.Observe(value, metric.WithAttributes(
+			attribute.String("receiver", t.receiver),
+			attribute.String("query", query),
+		))
+	}
+	return nil
+}
+
+// SetMeterProvider - reports scraper's internal metrics through the meter provider,
+// receivers pass the collector's TelemetrySettings.MeterProvider, nil keeps metrics off
+func (g *Scraper) SetMeterProvider(meterProvider metric.MeterProvider) error {
+	if meterProvider == nil {
+		meterProvider = noop.NewMeterProvider()
+	}
+	telemetry, err := newScraperTelemetry(g.name, meterProvider)
+	if err != nil {
+		return err
+	}
+	g.telemetry = telemetry
+	return nil
+}
+
+func newNoopTelemetry(receiver string) *scraperTelemetry {
+	// noop instruments never fail
+	telemetry, _ := newScraperTelemetry(receiver, noop.NewMeterProvider())
+	return telemetry
+}
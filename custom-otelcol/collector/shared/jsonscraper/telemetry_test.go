@@ -0,0 +1,183 @@
+package jsonscraper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+)
+
+// recordingMeter - keeps sums of counters and counts of histogram records keyed on
+// name{attributes}, the observable gauge is kept for collect
+type recordingMeter struct {
+	noop.Meter
+	mutex    sync.Mutex
+	values   map[string]float64
+	callback metric.Int64Callback
+}
+
+type recordingMeterProvider struct {
+	noop.MeterProvider
+	meter *recordingMeter
+}
+
+func (p *recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return p.meter
+}
+
+func (m *recordingMeter) add(name string, value float64, attrs attribute.Set) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.values[telemetryKey(name, attrs)] += value
+}
+
+func telemetryKey(name string, attrs attribute.Set) string {
+	pairs := []string{}
+	for _, kv := range attrs.ToSlice() {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", kv.Key, kv.Value.Emit()))
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+type recordingCounter struct {
+	noop.Int64Counter
+	name  string
+	meter *recordingMeter
+}
+
+func (c *recordingCounter) Add(_ context.Context, incr int64, options ...metric.AddOption) {
+	c.meter.add(c.name, float64(incr), metric.NewAddConfig(options).Attributes())
+}
+
+type recordingHistogram struct {
+	noop.Float64Histogram
+	name  string
+	meter *recordingMeter
+}
+
+func (h *recordingHistogram) Record(_ context.Context, _ float64, options ...metric.RecordOption) {
+	h.meter.add(h.name, 1, metric.NewRecordConfig(options).Attributes())
+}
+
+func (m *recordingMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return &recordingCounter{name: name, meter: m}, nil
+}
+
+func (m *recordingMeter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return &recordingHistogram{name: name, meter: m}, nil
+}
+
+func (m *recordingMeter) Int64ObservableGauge(name string, options ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	callbacks := metric.NewInt64ObservableGaugeConfig(options...).Callbacks()
+	if len(callbacks) > 0 {
+		m.callback = callbacks[0]
+	}
+	return noop.Int64ObservableGauge{}, nil
+}
+
+type recordingObserver struct {
+	embedded.Int64Observer
+	name  string
+	meter *recordingMeter
+}
+
+func (o *recordingObserver) Observe(value int64, options ...metric.ObserveOption) {
+	o.meter.add(o.name, float64(value), metric.NewObserveConfig(options).Attributes())
+}
+
+func (m *recordingMeter) collect() map[string]float64 {
+	m.callback(context.Background(), &recordingObserver{name: "jsonscraper_scrape_success", meter: m})
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	values := map[string]float64{}
+	for key, value := range m.values {
+		values[key] = value
+	}
+	return values
+}
+
+const telemetryTestQueries = `
+queries:
+- name: Nodes
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /api/class/fabricNode.json
+    select: /imdata/*
+    forEach:
+      emitMetric:
+      - name: node.id
+        type: gauge
+        valueFrom: =double(jqs("fabricNode/attributes/id"))
+      - name: node.broken
+        type: gauge
+        valueFrom: =double(jqs("fabricNode/attributes/missing"))
+- name: Broken
+  resource:
+    name: ACI
+  scope:
+    name: test
+  rules:
+    query: /api/class/missing.json
+`
+
+func TestScraperTelemetry(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(telemetryTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/api/class/fabricNode.json": `{"imdata":[{"fabricNode":{"attributes":{"id":"101"}}},{"fabricNode":{"attributes":{"id":"102"}}}]}`,
+		},
+	}
+	metricConsumer, err := consumer.NewMetrics(func(context.Context, pmetric.Metrics) error { return nil })
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	meter := &recordingMeter{values: map[string]float64{}}
+	if err = scraper.SetMeterProvider(&recordingMeterProvider{meter: meter}); err != nil {
+		t.Fatalf("Cannot set meter provider - %v", err)
+	}
+
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	values := meter.collect()
+	expected := map[string]float64{
+		"jsonscraper_scrape_duration{query=Nodes,receiver=test}":                                              1,
+		"jsonscraper_scrape_duration{query=Broken,receiver=test}":                                             1,
+		"jsonscraper_scrape_success{query=Nodes,receiver=test}":                                               1,
+		"jsonscraper_scrape_success{query=Broken,receiver=test}":                                              0,
+		"jsonscraper_http_requests{outcome=success,query=Nodes,receiver=test,url=/api/class/fabricNode.json}": 1,
+		"jsonscraper_http_requests{outcome=failure,query=Broken,receiver=test,url=/api/class/missing.json}":   1,
+		"jsonscraper_http_duration{query=Nodes,receiver=test,url=/api/class/fabricNode.json}":                 1,
+		"jsonscraper_items_selected{query=Nodes,receiver=test,rule=rules}":                                    2,
+		"jsonscraper_expression_errors{query=Nodes,receiver=test,rule=rules/forEach}":                         2,
+		"jsonscraper_emitted{query=Nodes,receiver=test,signal=metric}":                                        2,
+	}
+	for key, value := range expected {
+		if actual, ok := values[key]; !ok || actual != value {
+			t.Errorf("%s expected: %v != actual: %v\nall: %v", key, value, actual, values)
+		}
+	}
+}
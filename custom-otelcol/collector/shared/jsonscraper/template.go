@@ -0,0 +1,162 @@
+package jsonscraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/jsonquery"
+)
+
+// Templates in query URLs and POST bodies:
+//
+//	${name}          parameter value, error if the parameter is not set
+//	${name:-text}    parameter value, text if the parameter is not set or empty
+//	${= expr}        result of the expression evaluated on the current document
+//	${name | url}    value escaped as URL query parameter, filters can be chained
+//	$${              literal ${
+//
+// Filters: url (query escaping), path (path escaping keeping /), json (inside JSON string), raw
+var templateFilterRegexp = regexp.MustCompile(`^(?s)(.*[^|])\|\s*(url|path|json|raw)\s*$`)
+
+var templateFilters = map[string]func(string) string{
+	"url":  url.QueryEscape,
+	"path": escapePath,
+	"json": escapeJSON,
+	"raw":  func(s string) string { return s },
+}
+
+// fillTemplate - replaces all ${...} placeholders in the template
+func (g *Scraper) fillTemplate(templ string, doc *jsonquery.Node, scContext *scraperContext) (string, error) {
+	result := strings.Builder{}
+
+	for i := 0; i < len(templ); i++ {
+		if templ[i] != '$' || i+1 >= len(templ) {
+			result.WriteByte(templ[i])
+			continue
+		}
+		if strings.HasPrefix(templ[i:], "$${") {
+			result.WriteString("${")
+			i += 2
+			continue
+		}
+		if templ[i+1] != '{' {
+			result.WriteByte(templ[i])
+			continue
+		}
+
+		end, err := placeholderEnd(templ, i+2)
+		if err != nil {
+			return "", fmt.Errorf("template %s - %v", templ, err)
+		}
+		value, err := g.resolvePlaceholder(templ[i+2:end], doc, scContext)
+		if err != nil {
+			return "", fmt.Errorf("template %s - %v", templ, err)
+		}
+		result.WriteString(value)
+		i = end
+	}
+
+	return result.String(), nil
+}
+
+// placeholderEnd - returns index of } closing the placeholder starting at start,
+// braces and quoted strings within expressions are skipped
+func placeholderEnd(templ string, start int) (int, error) {
+	depth := 1
+	var quote byte
+	for i := start; i < len(templ); i++ {
+		c := templ[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unterminated placeholder at %d", start-2)
+}
+
+func (g *Scraper) resolvePlaceholder(placeholder string, doc *jsonquery.Node, scContext *scraperContext) (string, error) {
+	filters := []string{}
+	for {
+		match := templateFilterRegexp.FindStringSubmatch(placeholder)
+		if match == nil {
+			break
+		}
+		filters = append([]string{match[2]}, filters...)
+		placeholder = match[1]
+	}
+	placeholder = strings.TrimSpace(placeholder)
+
+	var value string
+	if strings.HasPrefix(placeholder, "=") {
+		valueAny, err := g.evaluateValueFrom(doc, placeholder, scContext)
+		if err != nil {
+			return "", fmt.Errorf("cannot evaluate %s - %v", placeholder, err)
+		}
+		value = formatTemplateValue(valueAny)
+	} else {
+		name, defaultValue, hasDefault := strings.Cut(placeholder, ":-")
+		name = strings.TrimSpace(name)
+		valueAny, ok := scContext.getParameters()[name]
+		if ok {
+			value = formatTemplateValue(valueAny)
+		}
+		switch {
+		case hasDefault && (!ok || value == ""):
+			value = defaultValue
+		case !ok:
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+	}
+
+	for _, filter := range filters {
+		value = templateFilters[filter](value)
+	}
+	return value, nil
+}
+
+func formatTemplateValue(value any) string {
+	if holder, ok := value.(valueHolder); ok {
+		value = holder.Value()
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// escapePath - escapes path segments, slashes are kept
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapeJSON - escapes value to be placed inside JSON string, without the quotes
+func escapeJSON(value string) string {
+	escaped, _ := json.Marshal(value)
+	return string(escaped[1 : len(escaped)-1])
+}
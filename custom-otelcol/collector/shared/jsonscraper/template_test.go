@@ -0,0 +1,51 @@
+package jsonscraper
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/antchfx/jsonquery"
+)
+
+func TestFillTemplate(t *testing.T) {
+	scraper := newTestScraper(t, &fakeClient{})
+	doc, err := jsonquery.Parse(strings.NewReader(`{"count":"3","name":"leaf 1"}`))
+	if err != nil {
+		t.Fatalf("Cannot parse document - %v", err)
+	}
+	scContext := newScaperContext()
+	scContext.push()
+	scContext.addParameter("dn", "topology/pod-1/node-101/sys/phys-[eth1/1]")
+	scContext.addParameter("filter", `name eq 'a&b'`)
+	scContext.addParameter("from", `2023-05-30T00:00:00"Z`)
+	scContext.addParameter("empty", "")
+
+	tests := []struct {
+		templ    string
+		expected string
+	}{
+		{"/api/node/mo/${dn}.json", "/api/node/mo/topology/pod-1/node-101/sys/phys-[eth1/1].json"},
+		{"/api/node/mo/${dn | path}.json", "/api/node/mo/topology/pod-1/node-101/sys/phys-%5Beth1/1%5D.json"},
+		{"/api/v1/view?$filter=${filter|url}&$top=10", "/api/v1/view?$filter=name+eq+%27a%26b%27&$top=10"},
+		{`{"intervals":["${from | json}/${missing:-now}"]}`, `{"intervals":["2023-05-30T00:00:00\"Z/now"]}`},
+		{"/items?size=${empty:-100}", "/items?size=100"},
+		{`/items?size=${= int(jqs("count")) * 10}&name=${= jqs("name") == "" || true ? jqs("name") : "" | url}`, "/items?size=30&name=leaf+1"},
+		{`${= {"a": "}"}["a"]}`, "}"},
+		{"literal $${dn} and $5", "literal ${dn} and $5"},
+	}
+	for _, test := range tests {
+		actual, err := scraper.fillTemplate(test.templ, doc, &scContext)
+		if err != nil {
+			t.Fatalf("Cannot fill template %s - %v", test.templ, err)
+		}
+		if actual != test.expected {
+			t.Fatalf("expected: %s != actual: %s", test.expected, actual)
+		}
+	}
+
+	for _, templ := range []string{"/api/${missing}", "/api/${dn"} {
+		if _, err := scraper.fillTemplate(templ, doc, &scContext); err == nil {
+			t.Fatalf("expected error for template %s", templ)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package jsonscraper
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antchfx/jsonquery"
+)
+
+const (
+	TimestampEpochMs = "epoch_ms" // milliseconds since 1970-01-01 UTC
+	TimestampEpochS  = "epoch_s"  // seconds since 1970-01-01 UTC, fractions allowed
+	TimestampISO8601 = "iso8601"  // with or without zone offset, fractions of second optional
+)
+
+var iso8601Layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+var locations = sync.Map{}
+
+// loadLocation - returns cached time zone, empty name is UTC
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %s - %v", name, err)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// evaluateTimestamp - evaluates expression and parses the result, empty expression returns zero time
+func (g *Scraper) evaluateTimestamp(expr string, format string, timezone string, doc *jsonquery.Node, scContext *scraperContext) (time.Time, error) {
+	if expr == "" {
+		return time.Time{}, nil
+	}
+	valueAny, err := g.evaluateRawValueFrom(doc, expr, scContext)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Cannot evaluate expr %s - %v", expr, err)
+	}
+	return parseTimestamp(valueAny, format, timezone)
+}
+
+// parseTimestamp - parses value according to format - epoch_ms, epoch_s, iso8601, or Go time layout.
+// Empty format is RFC3339. Timezone applies to values without zone offset.
+func parseTimestamp(value any, format string, timezone string) (time.Time, error) {
+	if holder, ok := value.(valueHolder); ok {
+		value = holder.Value()
+	}
+	if t, ok := value.(time.Time); ok {
+		return t, nil
+	}
+
+	loc, err := loadLocation(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	switch format {
+	case TimestampEpochMs, TimestampEpochS:
+		epoch, err := toFloat(value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp %v is not %s - %v", value, format, err)
+		}
+		if format == TimestampEpochS {
+			epoch *= 1000
+		}
+		ms, fraction := math.Modf(epoch)
+		return time.UnixMilli(int64(ms)).Add(time.Duration(fraction * float64(time.Millisecond))), nil
+	}
+
+	str := strings.TrimSpace(fmt.Sprintf("%v", value))
+	switch format {
+	case "":
+		t, err := time.ParseInLocation(time.RFC3339, str, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp %s is not RFC3339 - %v", str, err)
+		}
+		return t, nil
+	case TimestampISO8601:
+		for _, layout := range iso8601Layouts {
+			if t, err := time.ParseInLocation(layout, str, loc); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("timestamp %s is not ISO 8601", str)
+	default:
+		t, err := time.ParseInLocation(format, str, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp %s does not match layout %s - %v", str, format, err)
+		}
+		return t, nil
+	}
+}
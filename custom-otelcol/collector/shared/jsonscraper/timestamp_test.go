@@ -0,0 +1,105 @@
+package jsonscraper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/collector/consumer"
+	"go.uber.org/zap"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Skipf("No time zone data - %v", err)
+	}
+	tests := []struct {
+		value    any
+		format   string
+		timezone string
+		expected time.Time
+	}{
+		{"2023-05-30T15:16:26.896+02:00", "", "", time.Date(2023, 5, 30, 13, 16, 26, 896000000, time.UTC)},
+		{"1685459786896", TimestampEpochMs, "", time.Date(2023, 5, 30, 15, 16, 26, 896000000, time.UTC)},
+		{1685459786896.0, TimestampEpochMs, "", time.Date(2023, 5, 30, 15, 16, 26, 896000000, time.UTC)},
+		{"1685459786.5", TimestampEpochS, "", time.Date(2023, 5, 30, 15, 16, 26, 500000000, time.UTC)},
+		{"2023-05-30T15:16:26Z", TimestampISO8601, "", time.Date(2023, 5, 30, 15, 16, 26, 0, time.UTC)},
+		{"2023-05-30T15:16:26.000+0200", TimestampISO8601, "", time.Date(2023, 5, 30, 13, 16, 26, 0, time.UTC)},
+		{"2023-05-30T15:16:26", TimestampISO8601, "Europe/Prague", time.Date(2023, 5, 30, 15, 16, 26, 0, prague)},
+		{"30/05/2023 15:16", "02/01/2006 15:04", "Europe/Prague", time.Date(2023, 5, 30, 15, 16, 0, 0, prague)},
+	}
+
+	for _, test := range tests {
+		actual, err := parseTimestamp(test.value, test.format, test.timezone)
+		if err != nil {
+			t.Fatalf("%v as %s - %v", test.value, test.format, err)
+		}
+		if !actual.Equal(test.expected) {
+			t.Errorf("%v as %s: expected: %v != actual: %v", test.value, test.format, test.expected, actual)
+		}
+	}
+
+	if _, err := parseTimestamp("yesterday", TimestampISO8601, ""); err == nil {
+		t.Errorf("expected error for invalid timestamp")
+	}
+	if _, err := parseTimestamp("2023-05-30", TimestampISO8601, "Mars/Olympus"); err == nil {
+		t.Errorf("expected error for unknown timezone")
+	}
+}
+
+const timestampTestQueries = `
+queries:
+- name: Energy
+  resource:
+    name: Intersight
+  scope:
+    name: test
+  rules:
+    query: /timeseries
+    select: '*'
+    forEach:
+      emitMetric:
+      - name: energy
+        type: gauge
+        valueFrom: event/energyConsumed
+        timestampFrom: timestamp
+        timestampFormat: iso8601
+`
+
+func TestMetricTimestampFrom(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRules([]byte(timestampTestQueries))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	client := &fakeClient{
+		responses: map[string]string{
+			"/timeseries": `[{"timestamp":"2023-05-30T15:16:00.000Z","event":{"energyConsumed":10}},{"timestamp":"2023-05-30T15:17:00.000Z","event":{"energyConsumed":12}}]`,
+		},
+	}
+
+	sink := &batchSink{}
+	metricConsumer, err := consumer.NewMetrics(sink.consume)
+	if err != nil {
+		t.Fatalf("Cannot create consumer - %v", err)
+	}
+	logger := zap.NewNop()
+	emitter := NewEmitter(context.Background(), logger, metricConsumer, nil)
+	scraper := NewScraper("test", logger, client, emitter, config, 60, nil)
+	err = scraper.scrape(context.Background(), config.Queries, 60)
+	if err != nil {
+		t.Fatalf("Scrape failed - %v", err)
+	}
+
+	dps := sink.bundles[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints()
+	if dps.Len() != 2 {
+		t.Fatalf("expected: 2 data points != actual: %d", dps.Len())
+	}
+	for i, minute := range []int{16, 17} {
+		expected := time.Date(2023, 5, 30, 15, minute, 0, 0, time.UTC)
+		if !dps.At(i).Timestamp().AsTime().Equal(expected) || !dps.At(i).StartTimestamp().AsTime().Equal(expected.Add(-time.Minute)) {
+			t.Errorf("expected: %v != actual: %v (start %v)", expected, dps.At(i).Timestamp().AsTime(), dps.At(i).StartTimestamp().AsTime())
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package jsonscraper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	expr "github.com/chrlic/otelcol-cust/collector/shared/expressions"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// tableRef - emitDbRecord's table, checked by ValidateTables once the schemas are known
+type tableRef struct {
+	source string
+	line   int
+	column int
+	table  string
+}
+
+// validator - checks query file against the config types: unknown keys, invalid enum values,
+// expressions which do not compile, and reducer maps without declared reducer. Errors carry
+// file, line and column.
+type validator struct {
+	source   string
+	origins  map[*yaml.Node]string // file of the node when it comes from an included file
+	env      *expr.ExpressionEnvironment
+	errs     []error
+	reducers []map[string]bool // reducers declared by the rule and its parents
+	tables   []tableRef
+}
+
+type enumValue interface {
+	IsValid() bool
+}
+
+var (
+	enumValueType  = reflect.TypeOf((*enumValue)(nil)).Elem()
+	ruleType       = reflect.TypeOf(Rule{})
+	metricEmitType = reflect.TypeOf(MetricEmit{})
+	dbEmitType     = reflect.TypeOf(DBEmit{})
+	emitEachType   = reflect.TypeOf(EmitEach{})
+)
+
+func newValidator(source string, origins map[*yaml.Node]string) (*validator, error) {
+	env := &expr.ExpressionEnvironment{}
+	if err := env.InitEnv(zap.NewNop(), nil); err != nil {
+		return nil, fmt.Errorf("cannot create expression environment - %v", err)
+	}
+	return &validator{
+		source:  source,
+		origins: origins,
+		env:     env,
+	}, nil
+}
+
+func (v *validator) sourceOf(node *yaml.Node) string {
+	if source, ok := v.origins[node]; ok {
+		return source
+	}
+	return v.source
+}
+
+func (v *validator) errorf(node *yaml.Node, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s:%d:%d: %s", v.sourceOf(node), node.Line, node.Column, fmt.Sprintf(format, args...)))
+}
+
+// validate - checks every node of the expanded query file, returns all errors found
+func (v *validator) validate(root *yaml.Node) error {
+	v.walk(root, reflect.TypeOf(Config{}))
+	return errors.Join(v.errs...)
+}
+
+func (v *validator) walk(node *yaml.Node, t reflect.Type) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, content := range node.Content {
+			v.walk(content, t)
+		}
+		return
+	case yaml.AliasNode:
+		v.walk(node.Alias, t)
+		return
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "expected mapping for %s", t.Name())
+			return
+		}
+		v.walkStruct(node, t)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		if node.Kind != yaml.SequenceNode {
+			v.errorf(node, "expected list of %s", t.Elem().Name())
+			return
+		}
+		for _, item := range node.Content {
+			v.walk(item, t.Elem())
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			v.errorf(node, "expected mapping")
+			return
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			v.walk(node.Content[i], t.Elem())
+		}
+	case reflect.Interface:
+		// any value, e.g. default
+	default:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "expected %s value", t.Kind())
+		}
+	}
+}
+
+// checkEnum - checks values of types like MetricType or ErrorPolicy by their IsValid
+func (v *validator) checkEnum(key string, node *yaml.Node, t reflect.Type) {
+	if node.Kind != yaml.ScalarNode || t.Kind() != reflect.String || !t.Implements(enumValueType) {
+		return
+	}
+	value := reflect.New(t).Elem()
+	value.SetString(node.Value)
+	if !value.Interface().(enumValue).IsValid() {
+		v.errorf(node, "invalid %s %s", key, node.Value)
+	}
+}
+
+func (v *validator) walkStruct(node *yaml.Node, t reflect.Type) {
+	fields := yamlFields(t)
+
+	if t == ruleType {
+		v.pushReducers(node)
+		defer func() {
+			v.reducers = v.reducers[:len(v.reducers)-1]
+		}()
+	}
+	if t == metricEmitType && mappingValue(node, "type") == nil {
+		v.errorf(node, "metric %s has no type", scalarValue(mappingValue(node, "name")))
+	}
+	if t == metricEmitType && mappingValue(node, "emitEach") != nil {
+		if metricType := MetricType(scalarValue(mappingValue(node, "type"))); metricType == Histogram || metricType == Summary {
+			v.errorf(node, "emitEach cannot be used with metric type %s", metricType)
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field, ok := fields[key.Value]
+		if !ok {
+			v.errorf(key, "unknown field %s in %s", key.Value, t.Name())
+			continue
+		}
+		v.walk(value, field.Type)
+		v.checkEnum(key.Value, value, field.Type)
+
+		switch {
+		case isExpressionField(key.Value) && isStringField(field.Type):
+			v.checkExpression(key.Value, value)
+		case t == dbEmitType && key.Value == "db":
+			v.tables = append(v.tables, tableRef{source: v.sourceOf(value), line: value.Line, column: value.Column, table: value.Value})
+		case t == ruleType && key.Value == "reducerMaps" && value.Kind == yaml.SequenceNode:
+			for _, reducerMap := range value.Content {
+				if name := mappingValue(reducerMap, "name"); name != nil && !v.reducerDeclared(name.Value) {
+					v.errorf(name, "reducer %s is not declared in reducers of the rule or its parents", name.Value)
+				}
+			}
+		case t == emitEachType && (key.Value == "include" || key.Value == "exclude"):
+			if _, err := regexp.Compile(value.Value); err != nil {
+				v.errorf(value, "invalid %s %s - %v", key.Value, value.Value, err)
+			}
+		case t == metricEmitType && key.Value == "forEachGroup" && value.Value != "" && !v.reducerDeclared(value.Value):
+			v.errorf(value, "reducer %s is not declared in reducers of the rule or its parents", value.Value)
+		}
+	}
+}
+
+// isExpressionField - when and filter's is always are expressions, xxxFrom and groupBy fields
+// are expressions when they start with =, jsonquery paths otherwise
+func isExpressionField(key string) bool {
+	return key == "when" || key == "is" || key == "groupBy" || strings.HasSuffix(key, "From")
+}
+
+func isStringField(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.String
+}
+
+func (v *validator) checkExpression(key string, node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode || node.Value == "" {
+		return
+	}
+	expression := node.Value
+	switch {
+	case strings.HasPrefix(expression, "="):
+		expression = expression[1:]
+	case key != "when":
+		return
+	}
+	if err := v.env.CheckExpression(expression); err != nil {
+		v.errorf(node, "invalid expression %s - %v", node.Value, strings.ReplaceAll(err.Error(), "\n", " "))
+	}
+}
+
+func (v *validator) pushReducers(rule *yaml.Node) {
+	declared := map[string]bool{}
+	if len(v.reducers) > 0 {
+		for name := range v.reducers[len(v.reducers)-1] {
+			declared[name] = true
+		}
+	}
+	if reducers := mappingValue(rule, "reducers"); reducers != nil && reducers.Kind == yaml.SequenceNode {
+		for _, reducer := range reducers.Content {
+			declared[reducer.Value] = true
+		}
+	}
+	v.reducers = append(v.reducers, declared)
+}
+
+func (v *validator) reducerDeclared(name string) bool {
+	return len(v.reducers) > 0 && v.reducers[len(v.reducers)-1][name]
+}
+
+// yamlFields - fields of the struct by their yaml key
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func scalarValue(node *yaml.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Value
+}
+
+// ValidateTables - checks that tables of all emitDbRecord rules are among the tables
+// of the loaded DB schemas
+func (c *Config) ValidateTables(tables []string) error {
+	known := map[string]bool{}
+	for _, table := range tables {
+		known[table] = true
+	}
+	errs := []error{}
+	for _, ref := range c.tableRefs {
+		if !known[ref.table] {
+			errs = append(errs, fmt.Errorf("%s:%d:%d: table %s is not defined in table schemas", ref.source, ref.line, ref.column, ref.table))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,156 @@
+package jsonscraper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateQueryFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		queries  string
+		expected []string
+	}{
+		{
+			name: "valid",
+			queries: `
+queries:
+- name: Valid
+  rules:
+    query: /nodes
+    reducers:
+    - total
+    forEach:
+      reducerMaps:
+      - name: total
+        valueFrom: =double(jqs("count"))
+      emitMetric:
+      - name: count
+        type: gauge
+        valueFrom: =double(jqs("count"))
+`,
+		},
+		{
+			name: "unknown field",
+			queries: `
+queries:
+- name: Unknown
+  rules:
+    query: /nodes
+    emitMetrics:
+    - name: count
+`,
+			expected: []string{"test.yaml:6:5: unknown field emitMetrics in Rule"},
+		},
+		{
+			name: "invalid expression",
+			queries: `
+queries:
+- name: Expression
+  rules:
+    query: /nodes
+    when: =jqs("count") >
+    emitMetric:
+    - name: count
+      type: gauge
+      valueFrom: =double(jqs("count")
+`,
+			expected: []string{"test.yaml:6:11: invalid expression", "test.yaml:10:18: invalid expression"},
+		},
+		{
+			name: "undeclared reducer",
+			queries: `
+queries:
+- name: Reducer
+  rules:
+    query: /nodes
+    reducerMaps:
+    - name: total
+      value: 1
+      groupBy: =jqs("pod"
+    emitMetric:
+    - name: total
+      type: gauge
+      forEachGroup: total
+      valueFrom: =reducerMap("total").sumReducer()
+`,
+			expected: []string{"test.yaml:7:13: reducer total is not declared", "test.yaml:9:16: invalid expression", "test.yaml:13:21: reducer total is not declared"},
+		},
+		{
+			name: "metric type",
+			queries: `
+queries:
+- name: Type
+  rules:
+    query: /nodes
+    emitMetric:
+    - name: untyped
+      valueFrom: count
+    - name: counter
+      type: counter
+      valueFrom: count
+`,
+			expected: []string{"test.yaml:7:7: metric untyped has no type", "test.yaml:10:13: invalid type counter"},
+		},
+		{
+			name: "emit each",
+			queries: `
+queries:
+- name: EmitEach
+  rules:
+    query: /stats
+    emitMetric:
+    - type: histogram
+      emitEach:
+        namePrefix: stats.
+    - type: gauge
+      nameFrom: =jqs("name"
+      emitEach:
+        include: ^(unicast
+`,
+			expected: []string{"test.yaml:7:7: emitEach cannot be used with metric type histogram", "test.yaml:11:17: invalid expression", "test.yaml:13:18: invalid include ^(unicast"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			config := NewScraperConfig()
+			err := config.AddQueryRulesFrom("test.yaml", []byte(test.queries))
+			if len(test.expected) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error - %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %v", test.expected)
+			}
+			for _, expected := range test.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Fatalf("expected: %s not in actual: %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTables(t *testing.T) {
+	config := NewScraperConfig()
+	err := config.AddQueryRulesFrom("test.yaml", []byte(`
+queries:
+- name: Tables
+  rules:
+    query: /nodes
+    emitDbRecord:
+    - db: nodes
+    - db: missing
+`))
+	if err != nil {
+		t.Fatalf("Cannot parse queries - %v", err)
+	}
+	err = config.ValidateTables([]string{"nodes"})
+	if err == nil || err.Error() != "test.yaml:8:11: table missing is not defined in table schemas" {
+		t.Fatalf("expected missing table error, got %v", err)
+	}
+}